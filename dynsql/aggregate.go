@@ -0,0 +1,198 @@
+package dynsql
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Bucket 聚合结果
+type Bucket struct {
+	Key   any        `json:"key"`            // 分组列的值，已经按照列的类型转换，没有分组列时为 nil
+	Time  *time.Time `json:"time,omitempty"` // 时间分桶的起始时间
+	Value float64    `json:"value"`          // 聚合值
+}
+
+var aggFuncSchemas = []*operatorSchema{
+	{Name: "数量", Op: "count"},
+	{Name: "去重数量", Op: "count_distinct"},
+	{Name: "最小值", Op: "min"},
+	{Name: "最大值", Op: "max"},
+	{Name: "总和", Op: "sum"},
+	{Name: "平均值", Op: "avg"},
+}
+
+var aggIntervalSchemas = []*operatorSchema{
+	{Name: "小时", Op: "hour"},
+	{Name: "天", Op: "day"},
+	{Name: "周", Op: "week"},
+	{Name: "月", Op: "month"},
+}
+
+// aggIntervals 时间分桶的 SQL，结果统一为 2006-01-02 15:04:05 格式的桶起始时间，
+// 周从周一开始。
+var aggIntervals = map[string]string{
+	"hour":  "DATE_FORMAT(?, '%Y-%m-%d %H:00:00')",
+	"day":   "DATE_FORMAT(?, '%Y-%m-%d 00:00:00')",
+	"week":  "DATE_FORMAT(DATE_SUB(?, INTERVAL WEEKDAY(?) DAY), '%Y-%m-%d 00:00:00')",
+	"month": "DATE_FORMAT(?, '%Y-%m-01 00:00:00')",
+}
+
+var aggFuncs = map[string]string{
+	"count_distinct": "COUNT(DISTINCT ?)",
+	"min":            "MIN(?)",
+	"max":            "MAX(?)",
+	"sum":            "SUM(?)",
+	"avg":            "AVG(?)",
+}
+
+// aggregate 校验后的聚合条件
+type aggregate struct {
+	group    Column
+	date     string
+	interval string
+	fn       string
+	col      string
+}
+
+// numericType 可以做 min max sum avg 的类型
+func numericType(tp columnTyper) bool {
+	return tp == typeInt || tp == typeFloat || tp == typeDuration
+}
+
+func (tbl *tableEnv) aggregate(in *Aggregate) (*aggregate, error) {
+	if in.Group == "" && in.Date == "" {
+		return nil, errors.New("聚合查询必须指定分组列或时间分桶列")
+	}
+	agg := &aggregate{interval: in.Interval, fn: in.Func}
+	if agg.interval == "" {
+		agg.interval = "day"
+	}
+	if agg.fn == "" {
+		agg.fn = "count"
+	}
+	if _, ok := aggIntervals[agg.interval]; !ok {
+		return nil, &Error{name: "分桶间隔", value: in.Interval}
+	}
+
+	if in.Group != "" {
+		col, ok := tbl.groupMap[in.Group]
+		if !ok {
+			return nil, &Error{name: "分组条件", value: in.Group}
+		}
+		agg.group = col
+	}
+	if in.Date != "" {
+		col, ok := tbl.groupMap[in.Date]
+		if !ok || col.columnType() != typeTime {
+			return nil, &Error{name: "时间分桶列", value: in.Date}
+		}
+		agg.date = in.Date
+	}
+
+	switch agg.fn {
+	case "count":
+	case "count_distinct":
+		_, metric := tbl.metricMap[in.Col]
+		_, group := tbl.groupMap[in.Col]
+		if !metric && !group {
+			return nil, &Error{name: "聚合列", value: in.Col}
+		}
+		agg.col = in.Col
+	case "min", "max", "sum", "avg":
+		if _, ok := tbl.metricMap[in.Col]; !ok {
+			return nil, &Error{name: "聚合列", value: in.Col}
+		}
+		agg.col = in.Col
+	default:
+		return nil, &Error{name: "聚合函数", value: in.Func}
+	}
+
+	return agg, nil
+}
+
+// selectExpr 生成 SELECT 部分：key bucket value
+func (agg *aggregate) selectExpr() clause.Expr {
+	expr := clause.Expr{}
+	if agg.group != nil {
+		expr.SQL = "? AS `key`, "
+		expr.Vars = append(expr.Vars, clause.Column{Name: agg.group.columnName()})
+	} else {
+		expr.SQL = "NULL AS `key`, "
+	}
+	if agg.date != "" {
+		date := clause.Column{Name: agg.date}
+		expr.SQL += aggIntervals[agg.interval] + " AS `bucket`, "
+		expr.Vars = append(expr.Vars, date)
+		if agg.interval == "week" {
+			expr.Vars = append(expr.Vars, date)
+		}
+	} else {
+		expr.SQL += "NULL AS `bucket`, "
+	}
+	if agg.fn == "count" {
+		expr.SQL += "COUNT(*) AS `value`"
+	} else {
+		expr.SQL += aggFuncs[agg.fn] + " AS `value`"
+		expr.Vars = append(expr.Vars, clause.Column{Name: agg.col})
+	}
+
+	return expr
+}
+
+func (sc *scope) Aggregate(db *gorm.DB) ([]*Bucket, error) {
+	agg := sc.agg
+	if agg == nil {
+		return nil, errors.New("没有指定聚合条件")
+	}
+
+	sel := agg.selectExpr()
+	db = sc.Where(db).Select(sel.SQL, sel.Vars...)
+	var groups []string
+	if agg.group != nil {
+		groups = append(groups, "`key`")
+	}
+	if agg.date != "" {
+		groups = append(groups, "`bucket`")
+	}
+	for _, g := range groups {
+		db = db.Group(g)
+	}
+	for i := len(groups) - 1; i >= 0; i-- { // 先按时间排序
+		db = db.Order(groups[i])
+	}
+
+	var rows []*struct {
+		Key    sql.NullString  `gorm:"column:key"`
+		Bucket sql.NullString  `gorm:"column:bucket"`
+		Value  sql.NullFloat64 `gorm:"column:value"`
+	}
+	if err := db.Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	buckets := make([]*Bucket, 0, len(rows))
+	for _, row := range rows {
+		bkt := &Bucket{Value: row.Value.Float64}
+		if agg.group != nil && row.Key.Valid {
+			key, err := storedValue(agg.group.columnType(), row.Key.String)
+			if err != nil { // 无法转换的值（如：bool 列保存为 0/1）原样返回
+				key = row.Key.String
+			}
+			bkt.Key = key
+		}
+		if row.Bucket.Valid {
+			at, err := time.ParseInLocation(time.DateTime, row.Bucket.String, time.Local)
+			if err != nil {
+				return nil, err
+			}
+			bkt.Time = &at
+		}
+		buckets = append(buckets, bkt)
+	}
+
+	return buckets, nil
+}
@@ -1,14 +1,16 @@
 package dynsql
 
 import (
+	"fmt"
 	"strings"
 
 	"gorm.io/gorm/clause"
 )
 
 type Column interface {
-	inter(op, val string) (clause.Expression, error)
+	inter(op, val, sep string) (clause.Expression, error)
 	columnName() string
+	columnType() columnTyper
 	columnSchema() *columnSchema
 	nameSchema() *nameSchema
 }
@@ -27,13 +29,22 @@ func (tc *tableColumn) columnName() string {
 	return tc.column
 }
 
-func (tc *tableColumn) inter(op, val string) (clause.Expression, error) {
+func (tc *tableColumn) columnType() columnTyper {
+	return tc.tp
+}
+
+func (tc *tableColumn) inter(op, val, sep string) (clause.Expression, error) {
 	opr, ok := tc.opMap[op]
 	if !ok {
 		return nil, &Error{name: "运算符", value: op}
 	}
-	if opr == In || opr == NotIn {
-		return tc.splitBatch(opr, val)
+	switch opr {
+	case IsNull, NotNull:
+		return opr.expr(tc.column), nil
+	case In, NotIn:
+		return tc.splitBatch(opr, val, sep)
+	case Between:
+		return tc.between(val, sep)
 	}
 
 	if !tc.passEnum(val) {
@@ -45,13 +56,31 @@ func (tc *tableColumn) inter(op, val string) (clause.Expression, error) {
 		return nil, err
 	}
 
-	exp := opr.expr(tc.column, value)
+	return tc.expr(opr, value)
+}
+
+// expr 生成表达式，需要特殊 SQL 的类型（如：IP 网段、版本号）由类型自己处理。
+// 有值却生成不了表达式时返回错误，避免过滤条件被静默忽略。
+func (tc *tableColumn) expr(opr Operator, values ...any) (clause.Expression, error) {
+	var exp clause.Expression
+	if ce, ok := tc.tp.(columnExprer); ok {
+		var err error
+		if exp, err = ce.expr(opr, tc.column, values...); err != nil {
+			return nil, err
+		}
+	} else {
+		exp = opr.expr(tc.column, values...)
+	}
+	if exp == nil {
+		return nil, fmt.Errorf("%s 不支持运算符 %s", tc.name, opr.value())
+	}
 
 	return exp, nil
 }
 
-func (tc *tableColumn) splitBatch(opr Operator, val string) (clause.Expression, error) {
-	sn := strings.Split(val, "|")
+// splitBatch 按照 sep 拆分 IN / NOT IN 的多个值
+func (tc *tableColumn) splitBatch(opr Operator, val, sep string) (clause.Expression, error) {
+	sn := strings.Split(val, sep)
 
 	anis := make([]any, 0, len(sn))
 	for _, s := range sn {
@@ -71,9 +100,26 @@ func (tc *tableColumn) splitBatch(opr Operator, val string) (clause.Expression,
 		return nil, nil
 	}
 
-	exp := opr.expr(tc.column, anis...)
+	return tc.expr(opr, anis...)
+}
+
+// between 按照 sep 拆分区间的两端
+func (tc *tableColumn) between(val, sep string) (clause.Expression, error) {
+	sn := strings.Split(val, sep)
+	if len(sn) != 2 {
+		return nil, &Error{name: "区间", value: val}
+	}
+
+	values := make([]any, 0, 2)
+	for _, s := range sn {
+		value, err := tc.tp.cast(strings.TrimSpace(s))
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, value)
+	}
 
-	return exp, nil
+	return tc.expr(Between, values...)
 }
 
 func (tc *tableColumn) passEnum(str string) bool {
@@ -109,5 +155,6 @@ func (tc *tableColumn) nameSchema() *nameSchema {
 	return &nameSchema{
 		Col:  tc.column,
 		Name: tc.name,
+		Type: tc.tp.name(),
 	}
 }
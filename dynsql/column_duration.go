@@ -0,0 +1,44 @@
+package dynsql
+
+type DurationColumnBuilder interface {
+	Operators(ops []Operator) DurationColumnBuilder
+	Build() Column
+}
+
+// DurationColumn 时长列，数据库中保存为纳秒（time.Duration），输入支持 1h30m 或纳秒数。
+func DurationColumn(col, name string) DurationColumnBuilder {
+	return &durationColumnBuilder{
+		col:  col,
+		name: name,
+	}
+}
+
+type durationColumnBuilder struct {
+	col  string
+	name string
+	ops  []Operator
+}
+
+func (dc *durationColumnBuilder) Operators(ops []Operator) DurationColumnBuilder {
+	dc.ops = ops
+	return dc
+}
+
+func (dc *durationColumnBuilder) Build() Column {
+	if len(dc.ops) == 0 {
+		dc.ops = []Operator{Eq, Ne, Gt, Lt, Gte, Lte, Between}
+	}
+	opMap := make(map[string]Operator, len(dc.ops))
+	for _, op := range dc.ops {
+		val := op.value()
+		opMap[val] = op
+	}
+
+	return &tableColumn{
+		tp:     typeDuration,
+		column: dc.col,
+		name:   dc.name,
+		ops:    dc.ops,
+		opMap:  opMap,
+	}
+}
@@ -0,0 +1,44 @@
+package dynsql
+
+type FloatColumnBuilder interface {
+	Operators(ops []Operator) FloatColumnBuilder
+	Build() Column
+}
+
+// FloatColumn 浮点数列，如：model.CVSSScore。
+func FloatColumn(col, name string) FloatColumnBuilder {
+	return &floatColumnBuilder{
+		col:  col,
+		name: name,
+	}
+}
+
+type floatColumnBuilder struct {
+	col  string
+	name string
+	ops  []Operator
+}
+
+func (fc *floatColumnBuilder) Operators(ops []Operator) FloatColumnBuilder {
+	fc.ops = ops
+	return fc
+}
+
+func (fc *floatColumnBuilder) Build() Column {
+	if len(fc.ops) == 0 {
+		fc.ops = []Operator{Eq, Ne, Gt, Lt, Gte, Lte, Between}
+	}
+	opMap := make(map[string]Operator, len(fc.ops))
+	for _, op := range fc.ops {
+		val := op.value()
+		opMap[val] = op
+	}
+
+	return &tableColumn{
+		tp:     typeFloat,
+		column: fc.col,
+		name:   fc.name,
+		ops:    fc.ops,
+		opMap:  opMap,
+	}
+}
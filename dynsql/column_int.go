@@ -32,7 +32,7 @@ func (ic *intColumnBuilder) Enums(eb IntEnumBuilder) IntColumnBuilder {
 
 func (ic *intColumnBuilder) Build() Column {
 	if len(ic.ops) == 0 {
-		ic.ops = []Operator{Eq, Ne, Gt, Lt, Gte, Lte, In, NotIn}
+		ic.ops = []Operator{Eq, Ne, Gt, Lt, Gte, Lte, Between, In, NotIn}
 	}
 	opMap := make(map[string]Operator, len(ic.ops))
 	for _, op := range ic.ops {
@@ -53,7 +53,6 @@ func (ic *intColumnBuilder) Build() Column {
 		tc.enumMap = enum.toMap()
 	} else {
 		tc.enum = Enums{items: []*enumItem{}}
-		tc.enumMap = map[string]struct{}{}
 	}
 
 	return tc
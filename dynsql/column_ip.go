@@ -0,0 +1,44 @@
+package dynsql
+
+type IPColumnBuilder interface {
+	Operators(ops []Operator) IPColumnBuilder
+	Build() Column
+}
+
+// IPColumn IP 地址列，数据库中保存为字符串，支持 in_cidr 按网段查询。
+func IPColumn(col, name string) IPColumnBuilder {
+	return &ipColumnBuilder{
+		col:  col,
+		name: name,
+	}
+}
+
+type ipColumnBuilder struct {
+	col  string
+	name string
+	ops  []Operator
+}
+
+func (ic *ipColumnBuilder) Operators(ops []Operator) IPColumnBuilder {
+	ic.ops = ops
+	return ic
+}
+
+func (ic *ipColumnBuilder) Build() Column {
+	if len(ic.ops) == 0 {
+		ic.ops = []Operator{Eq, Ne, In, NotIn, InCIDR, NotInCIDR}
+	}
+	opMap := make(map[string]Operator, len(ic.ops))
+	for _, op := range ic.ops {
+		val := op.value()
+		opMap[val] = op
+	}
+
+	return &tableColumn{
+		tp:     typeIP,
+		column: ic.col,
+		name:   ic.name,
+		ops:    ic.ops,
+		opMap:  opMap,
+	}
+}
@@ -0,0 +1,54 @@
+package dynsql
+
+type SemverColumnBuilder interface {
+	Operators(ops []Operator) SemverColumnBuilder
+
+	// Weight 保存了 model.Semver.Int64 结果的列（如：minion_bin.weight），
+	// 指定后直接比较该列，否则在 SQL 中解析版本号字符串，无法使用索引。
+	Weight(col string) SemverColumnBuilder
+	Build() Column
+}
+
+// SemverColumn 语义化版本号列（model.Semver），按照版本号的大小比较。
+func SemverColumn(col, name string) SemverColumnBuilder {
+	return &semverColumnBuilder{
+		col:  col,
+		name: name,
+	}
+}
+
+type semverColumnBuilder struct {
+	col    string
+	name   string
+	ops    []Operator
+	weight string
+}
+
+func (sc *semverColumnBuilder) Operators(ops []Operator) SemverColumnBuilder {
+	sc.ops = ops
+	return sc
+}
+
+func (sc *semverColumnBuilder) Weight(col string) SemverColumnBuilder {
+	sc.weight = col
+	return sc
+}
+
+func (sc *semverColumnBuilder) Build() Column {
+	if len(sc.ops) == 0 {
+		sc.ops = []Operator{Eq, Ne, Gt, Lt, Gte, Lte, Between, In, NotIn}
+	}
+	opMap := make(map[string]Operator, len(sc.ops))
+	for _, op := range sc.ops {
+		val := op.value()
+		opMap[val] = op
+	}
+
+	return &tableColumn{
+		tp:     &semverColumnType{weight: sc.weight},
+		column: sc.col,
+		name:   sc.name,
+		ops:    sc.ops,
+		opMap:  opMap,
+	}
+}
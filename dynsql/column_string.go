@@ -32,7 +32,7 @@ func (sc *stringColumnBuilder) Enums(eb StringEnumBuilder) StringColumnBuilder {
 
 func (sc *stringColumnBuilder) Build() Column {
 	if len(sc.ops) == 0 {
-		sc.ops = []Operator{Eq, Ne, Gt, Lt, Gte, Lte, In, NotIn, Like, NotLike}
+		sc.ops = []Operator{Eq, Ne, Gt, Lt, Gte, Lte, In, NotIn, Like, NotLike, Prefix, Suffix}
 	}
 	opMap := make(map[string]Operator, len(sc.ops))
 	for _, op := range sc.ops {
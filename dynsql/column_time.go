@@ -25,7 +25,7 @@ func (tc *timeColumnBuilder) Operators(ops []Operator) TimeColumnBuilder {
 
 func (tc *timeColumnBuilder) Build() Column {
 	if len(tc.ops) == 0 {
-		tc.ops = []Operator{Eq, Ne, Gt, Lt, Gte, Lte}
+		tc.ops = []Operator{Eq, Ne, Gt, Lt, Gte, Lte, Between, IsNull, NotNull}
 	}
 	opMap := make(map[string]Operator, len(tc.ops))
 	for _, op := range tc.ops {
@@ -1,15 +1,26 @@
 package dynsql
 
 import (
+	"encoding/binary"
+	"errors"
+	"math"
+	"net/netip"
 	"strconv"
+	"strings"
 	"time"
+
+	"github.com/vela-ssoc/backend-common/model"
+	"gorm.io/gorm/clause"
 )
 
 var (
-	typeString columnTyper = &stringColumnType{}
-	typeInt    columnTyper = &intColumnType{}
-	typeBool   columnTyper = &boolColumnType{}
-	typeTime   columnTyper = &timeColumnType{}
+	typeString   columnTyper = &stringColumnType{}
+	typeInt      columnTyper = &intColumnType{}
+	typeBool     columnTyper = &boolColumnType{}
+	typeTime     columnTyper = &timeColumnType{}
+	typeFloat    columnTyper = &floatColumnType{}
+	typeIP       columnTyper = &ipColumnType{}
+	typeDuration columnTyper = &durationColumnType{}
 )
 
 type columnTyper interface {
@@ -17,6 +28,22 @@ type columnTyper interface {
 	cast(string) (any, error)
 }
 
+// columnExprer 需要特殊 SQL 的类型，实现后由类型自己生成表达式。
+type columnExprer interface {
+	expr(op Operator, col string, values ...any) (clause.Expression, error)
+}
+
+// supportOperator 类型是否支持该运算符：LIKE 类只适用于字符串，网段只适用于 IP。
+func supportOperator(tp columnTyper, op Operator) bool {
+	switch op {
+	case Like, NotLike, Prefix, Suffix:
+		return tp == typeString
+	case InCIDR, NotInCIDR:
+		return tp == typeIP
+	}
+	return true
+}
+
 type stringColumnType struct{}
 
 func (s *stringColumnType) name() string                 { return "string" }
@@ -34,5 +61,241 @@ func (b *boolColumnType) cast(str string) (any, error) { return strconv.ParseBoo
 
 type timeColumnType struct{}
 
-func (t *timeColumnType) name() string                 { return "time" }
-func (t *timeColumnType) cast(val string) (any, error) { return time.Parse(time.RFC3339, val) }
+func (t *timeColumnType) name() string { return "time" }
+func (t *timeColumnType) cast(val string) (any, error) {
+	if tm, ok, err := relativeTime(val); ok {
+		return tm, err
+	}
+	return time.Parse(time.RFC3339, val)
+}
+
+// timeNow 当前时间，方便测试替换。
+var timeNow = time.Now
+
+// relativeTime 解析相对时间：now 或 today（当天零点）后面可以跟一个偏移量，
+// 单位支持 s m h d w，如：now-7d、now-24h、today、today+8h。
+// ok 为 false 代表不是相对时间。
+func relativeTime(str string) (time.Time, bool, error) {
+	now := timeNow()
+	var base time.Time
+	var rest string
+	switch {
+	case strings.HasPrefix(str, "now"):
+		base, rest = now, str[3:]
+	case strings.HasPrefix(str, "today"):
+		year, month, day := now.Date()
+		base, rest = time.Date(year, month, day, 0, 0, 0, 0, now.Location()), str[5:]
+	default:
+		return time.Time{}, false, nil
+	}
+	if rest == "" {
+		return base, true, nil
+	}
+
+	err := errors.New("时间格式错误：" + str)
+	if len(rest) < 3 || (rest[0] != '+' && rest[0] != '-') {
+		return time.Time{}, true, err
+	}
+	num, perr := strconv.Atoi(rest[1 : len(rest)-1])
+	if perr != nil || num < 0 {
+		return time.Time{}, true, err
+	}
+	if rest[0] == '-' {
+		num = -num
+	}
+
+	switch rest[len(rest)-1] {
+	case 's':
+		return base.Add(time.Duration(num) * time.Second), true, nil
+	case 'm':
+		return base.Add(time.Duration(num) * time.Minute), true, nil
+	case 'h':
+		return base.Add(time.Duration(num) * time.Hour), true, nil
+	case 'd': // 按照日历天数计算，不受夏令时影响
+		return base.AddDate(0, 0, num), true, nil
+	case 'w':
+		return base.AddDate(0, 0, num*7), true, nil
+	default:
+		return time.Time{}, true, err
+	}
+}
+
+type floatColumnType struct{}
+
+func (f *floatColumnType) name() string { return "float" }
+func (f *floatColumnType) cast(str string) (any, error) {
+	num, err := strconv.ParseFloat(str, 64)
+	if err != nil {
+		return nil, err
+	}
+	if math.IsNaN(num) || math.IsInf(num, 0) {
+		return nil, strconv.ErrRange
+	}
+	return num, nil
+}
+
+// durationColumnType time.Duration 在数据库中保存为纳秒，输入支持 1h30m 或纳秒数。
+type durationColumnType struct{}
+
+func (d *durationColumnType) name() string { return "duration" }
+func (d *durationColumnType) cast(str string) (any, error) {
+	if du, err := time.ParseDuration(str); err == nil {
+		return int64(du), nil
+	}
+	return strconv.ParseInt(str, 10, 64)
+}
+
+// ipColumnType IP 地址，数据库中保存为字符串（如：model.Minion.Inet）。
+type ipColumnType struct{}
+
+func (i *ipColumnType) name() string { return "ip" }
+
+// cast 网段返回 netip.Prefix，单个 IP 返回 netip.Addr。
+func (i *ipColumnType) cast(str string) (any, error) {
+	if strings.Contains(str, "/") {
+		pfx, err := netip.ParsePrefix(str)
+		if err != nil {
+			return nil, err
+		}
+		return pfx.Masked(), nil
+	}
+	addr, err := netip.ParseAddr(str)
+	if err != nil {
+		return nil, err
+	}
+	return addr.Unmap(), nil
+}
+
+func (i *ipColumnType) expr(op Operator, col string, values ...any) (clause.Expression, error) {
+	if op == InCIDR || op == NotInCIDR {
+		if len(values) == 0 {
+			return nil, nil
+		}
+		var pfx netip.Prefix
+		switch v := values[0].(type) {
+		case netip.Prefix:
+			pfx = v
+		case netip.Addr:
+			pfx = netip.PrefixFrom(v, v.BitLen())
+		}
+		exp := cidrExpr(col, pfx)
+		if op == NotInCIDR {
+			return clause.Not(exp), nil
+		}
+		return exp, nil
+	}
+
+	// 其它运算符按照规范化后的字符串比较
+	strs := make([]any, 0, len(values))
+	for _, v := range values {
+		addr, ok := v.(netip.Addr)
+		if !ok {
+			return nil, errors.New("IP 地址不能是网段")
+		}
+		strs = append(strs, addr.String())
+	}
+
+	return op.expr(col, strs...), nil
+}
+
+// cidrExpr 将网段转为 IP 数值的区间
+func cidrExpr(col string, pfx netip.Prefix) clause.Expression {
+	column := clause.Column{Name: col}
+	first := pfx.Addr()
+	if first.Is4() {
+		start := binary.BigEndian.Uint32(first.AsSlice())
+		end := start | uint32(math.MaxUint32>>pfx.Bits())
+		return clause.Expr{
+			SQL:  "INET_ATON(?) BETWEEN ? AND ?",
+			Vars: []any{column, start, end},
+		}
+	}
+
+	start := first.As16()
+	end := start
+	for i := pfx.Bits(); i < 128; i++ {
+		end[i/8] |= 1 << (7 - i%8)
+	}
+	// IPv4 的 INET6_ATON 结果只有 4 字节，要排除掉
+	return clause.Expr{
+		SQL:  "LENGTH(INET6_ATON(?)) = 16 AND INET6_ATON(?) BETWEEN ? AND ?",
+		Vars: []any{column, column, start[:], end[:]},
+	}
+}
+
+// semverColumnType 语义化版本号，按照 model.Semver.Int64 的规则比较大小。
+type semverColumnType struct {
+	weight string // 保存了 model.Semver.Int64 结果的列，为空时在 SQL 中计算
+}
+
+func (s *semverColumnType) name() string { return "semver" }
+
+func (s *semverColumnType) cast(str string) (any, error) {
+	num := model.Semver(str).Int64()
+	if num == 0 && strings.Trim(str, "0.") != "" {
+		return nil, errors.New("版本号格式错误：" + str)
+	}
+	return num, nil
+}
+
+// semverSQL 在 SQL 中计算 model.Semver.Int64：按照 "." 最多拆成 3 段，不足 3 段时
+// 为 0，最后一段去掉 "-" 之后的预发布版本，不是整数的段按 0 计算，
+// 即 (major * 1000000 + minor) * 1000000 + patch。
+var semverSQL = "(CASE WHEN ? LIKE '%.%.%' THEN (" +
+	semverPart("SUBSTRING_INDEX(?, '.', 1)") + " * 1000000 + " +
+	semverPart("SUBSTRING_INDEX(SUBSTRING_INDEX(?, '.', 2), '.', -1)") + ") * 1000000 + " +
+	semverPart("SUBSTRING_INDEX(SUBSTRING(?, CHAR_LENGTH(SUBSTRING_INDEX(?, '.', 2)) + 2), '-', 1)") +
+	" ELSE 0 END)"
+
+// semverPart 与 strconv.ParseInt 一致，不是整数时为 0。正则中不能出现 ?，
+// 否则会被 gorm 当作参数占位符。
+func semverPart(part string) string {
+	return "IF(" + part + " REGEXP '^[+-]{0,1}[0-9]+$', CAST(" + part + " AS SIGNED), 0)"
+}
+
+func (s *semverColumnType) expr(op Operator, col string, values ...any) (clause.Expression, error) {
+	if s.weight != "" {
+		return op.expr(s.weight, values...), nil
+	}
+
+	column := clause.Column{Name: col}
+	vars := make([]any, strings.Count(semverSQL, "?"))
+	for i := range vars {
+		vars[i] = column
+	}
+	ver := clause.Expr{SQL: semverSQL, Vars: vars}
+
+	var symbol string
+	switch op {
+	case Eq:
+		symbol = "= ?"
+	case Ne:
+		symbol = "<> ?"
+	case Gt:
+		symbol = "> ?"
+	case Lt:
+		symbol = "< ?"
+	case Gte:
+		symbol = ">= ?"
+	case Lte:
+		symbol = "<= ?"
+	case Between:
+		if len(values) != 2 {
+			return nil, nil
+		}
+		return clause.Expr{SQL: ver.SQL + " BETWEEN ? AND ?", Vars: append(ver.Vars, values[0], values[1])}, nil
+	case In, NotIn:
+		symbol = "IN ?"
+		if op == NotIn {
+			symbol = "NOT IN ?"
+		}
+		return clause.Expr{SQL: ver.SQL + " " + symbol, Vars: append(ver.Vars, values)}, nil
+	default:
+		return nil, nil
+	}
+	if len(values) == 0 {
+		return nil, nil
+	}
+
+	return clause.Expr{SQL: ver.SQL + " " + symbol, Vars: append(ver.Vars, values[0])}, nil
+}
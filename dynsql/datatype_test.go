@@ -0,0 +1,251 @@
+package dynsql
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"unicode"
+
+	"github.com/vela-ssoc/backend-common/model"
+)
+
+// TestSemverSQL 按照 MySQL 的语义执行 semverSQL，结果要与 model.Semver.Int64 一致。
+func TestSemverSQL(t *testing.T) {
+	expr, err := SemverColumn("edition", "版本").Build().(*tableColumn).tp.(columnExprer).expr(Eq, "edition", int64(0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sb := new(sqlBuilder)
+	expr.Build(sb)
+	sql, ok := strings.CutSuffix(sb.String(), " = ?")
+	if !ok {
+		t.Fatalf("unexpected sql: %s", sb.String())
+	}
+
+	for _, ver := range []string{
+		"1.2.3", "0.0.1", "10.20.30", "1.2.3-rc.1", "1.2.3-beta-2", "v1.2.3", "1.2", "1", "",
+		"3+build", "1.2.3+build", "1.2.3.4", "1..3", "1.2.", "-1.2.3", "1.2.-3", "+1.2.3",
+		"a.b.c", "1.x.3", "1.2.3-", "１.2.3", "中文.2.3",
+	} {
+		want := model.Semver(ver).Int64()
+		query := strings.ReplaceAll(sql, "`edition`", "'"+ver+"'")
+		got, err := evalSQL(query)
+		if err != nil {
+			t.Fatalf("%q: %v", ver, err)
+		}
+		if got != want {
+			t.Fatalf("%q: sql %v, want %d", ver, got, want)
+		}
+	}
+}
+
+// evalSQL 执行只包含常量的 SQL 表达式，仅支持 semverSQL 用到的语法。
+func evalSQL(sql string) (any, error) {
+	p := &sqlParser{src: sql}
+	val := p.cond()
+	if p.err == nil && strings.TrimSpace(p.src[p.pos:]) != "" {
+		p.fail("unexpected " + p.src[p.pos:])
+	}
+	return val, p.err
+}
+
+type sqlParser struct {
+	src string
+	pos int
+	err error
+}
+
+func (p *sqlParser) fail(msg string) {
+	if p.err == nil {
+		p.err = &Error{name: "SQL", value: msg}
+	}
+}
+
+func (p *sqlParser) skip() {
+	for p.pos < len(p.src) && p.src[p.pos] == ' ' {
+		p.pos++
+	}
+}
+
+// accept 跳过期望的关键字或符号
+func (p *sqlParser) accept(tok string) bool {
+	p.skip()
+	if !strings.HasPrefix(p.src[p.pos:], tok) {
+		return false
+	}
+	p.pos += len(tok)
+	return true
+}
+
+func (p *sqlParser) expect(tok string) {
+	if !p.accept(tok) {
+		p.fail("expect " + tok + " at " + p.src[p.pos:])
+	}
+}
+
+// cond 比较运算：sum [LIKE 'x' | REGEXP 'x']
+func (p *sqlParser) cond() any {
+	left := p.sum()
+	switch {
+	case p.accept("LIKE"):
+		pattern := regexp.QuoteMeta(p.text())
+		pattern = strings.NewReplacer("%", ".*", "_", ".").Replace(pattern)
+		return regexp.MustCompile("^" + pattern + "$").MatchString(p.str(left))
+	case p.accept("REGEXP"):
+		return regexp.MustCompile(p.text()).MatchString(p.str(left))
+	}
+	return left
+}
+
+func (p *sqlParser) sum() any {
+	left := p.product()
+	for p.accept("+") {
+		left = p.int(left) + p.int(p.product())
+	}
+	return left
+}
+
+func (p *sqlParser) product() any {
+	left := p.primary()
+	for p.accept("*") {
+		left = p.int(left) * p.int(p.primary())
+	}
+	return left
+}
+
+func (p *sqlParser) primary() any {
+	p.skip()
+	if p.err != nil || p.pos >= len(p.src) {
+		p.fail("unexpected end")
+		return nil
+	}
+	switch c := p.src[p.pos]; {
+	case c == '\'':
+		return p.text()
+	case c == '-' || c >= '0' && c <= '9':
+		start := p.pos
+		p.pos++
+		for p.pos < len(p.src) && p.src[p.pos] >= '0' && p.src[p.pos] <= '9' {
+			p.pos++
+		}
+		n, err := strconv.ParseInt(p.src[start:p.pos], 10, 64)
+		if err != nil {
+			p.fail(err.Error())
+		}
+		return n
+	case c == '(':
+		p.pos++
+		val := p.cond()
+		p.expect(")")
+		return val
+	}
+
+	start := p.pos
+	for p.pos < len(p.src) && (p.src[p.pos] == '_' || unicode.IsUpper(rune(p.src[p.pos]))) {
+		p.pos++
+	}
+	name := p.src[start:p.pos]
+	if name == "CASE" {
+		p.expect("WHEN")
+		ok := p.cond()
+		p.expect("THEN")
+		then := p.cond()
+		p.expect("ELSE")
+		els := p.cond()
+		p.expect("END")
+		if ok == true {
+			return then
+		}
+		return els
+	}
+
+	p.expect("(")
+	var ret any
+	switch name {
+	case "IF":
+		ok := p.cond()
+		p.expect(",")
+		then := p.cond()
+		p.expect(",")
+		els := p.cond()
+		if ret = els; ok == true {
+			ret = then
+		}
+	case "CAST":
+		str := p.str(p.cond())
+		p.expect("AS SIGNED")
+		n, _ := strconv.ParseInt(str, 10, 64)
+		ret = n
+	case "CHAR_LENGTH":
+		ret = int64(len([]rune(p.str(p.cond()))))
+	case "SUBSTRING":
+		str := []rune(p.str(p.cond()))
+		p.expect(",")
+		pos := p.int(p.cond())
+		if ret = ""; pos >= 1 && pos <= int64(len(str)) {
+			ret = string(str[pos-1:])
+		}
+	case "SUBSTRING_INDEX":
+		str := p.str(p.cond())
+		p.expect(",")
+		delim := p.str(p.cond())
+		p.expect(",")
+		ret = substringIndex(str, delim, p.int(p.cond()))
+	default:
+		p.fail("unknown function " + name)
+	}
+	p.expect(")")
+
+	return ret
+}
+
+// text 读取单引号括起来的字符串，两个连续的单引号代表一个单引号。
+func (p *sqlParser) text() string {
+	p.expect("'")
+	var sb strings.Builder
+	for p.pos < len(p.src) {
+		c := p.src[p.pos]
+		p.pos++
+		if c != '\'' {
+			sb.WriteByte(c)
+		} else if p.pos < len(p.src) && p.src[p.pos] == '\'' {
+			sb.WriteByte(c)
+			p.pos++
+		} else {
+			return sb.String()
+		}
+	}
+	p.fail("unterminated string")
+	return ""
+}
+
+func (p *sqlParser) str(v any) string {
+	s, ok := v.(string)
+	if !ok {
+		p.fail("expect string")
+	}
+	return s
+}
+
+func (p *sqlParser) int(v any) int64 {
+	n, ok := v.(int64)
+	if !ok {
+		p.fail("expect integer")
+	}
+	return n
+}
+
+// substringIndex MySQL SUBSTRING_INDEX
+func substringIndex(str, delim string, count int64) string {
+	sn := strings.Split(str, delim)
+	switch {
+	case count > 0 && count < int64(len(sn)):
+		return strings.Join(sn[:count], delim)
+	case count < 0 && -count < int64(len(sn)):
+		return strings.Join(sn[int64(len(sn))+count:], delim)
+	case count == 0:
+		return ""
+	}
+	return str
+}
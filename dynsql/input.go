@@ -6,26 +6,103 @@ import (
 )
 
 type Input struct {
-	Filters []*filter `query:"filters" validate:"dive"`
+	Filters []*Filter `query:"filters" validate:"dive"`
+	Where   *Cond     `query:"where"`
 	Group   string    `query:"group"`
 	Order   string    `query:"order"`
 	Desc    bool      `query:"desc"`
+
+	// Orders 多列排序，按照顺序依次排序，不为空时忽略 Order 与 Desc。
+	Orders []*OrderBy `query:"orders" validate:"lte=5,dive"`
+
+	// Cursor 上一页返回的游标，为空代表第一页，配合 Scope.Page 使用。
+	Cursor string `query:"cursor" validate:"lte=2048"`
+
+	// Size 每页条数，默认 20，最大 1000。
+	Size int `query:"size" validate:"gte=0,lte=1000"`
+
+	// Agg 聚合条件，配合 Scope.Aggregate 使用。
+	Agg *Aggregate `query:"agg"`
 }
 
 func (in Input) empty() bool {
-	return len(in.Filters) == 0 && in.Group == "" && in.Order == ""
+	return len(in.Filters) == 0 && in.Where == nil && in.Group == "" && in.Order == "" &&
+		len(in.Orders) == 0 && in.Cursor == "" && in.Agg == nil
+}
+
+// OrderBy 排序条件
+type OrderBy struct {
+	Col  string `json:"col"  validate:"required,lte=50"`
+	Desc bool   `json:"desc"`
+}
+
+func (o *OrderBy) UnmarshalBind(raw string) error {
+	data, err := url.QueryUnescape(raw)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal([]byte(data), o)
 }
 
-type filter struct {
+// Filter 单个过滤条件，多个 Filter 之间为 AND。
+type Filter struct {
 	Col string `json:"col" validate:"required,lte=50"`
-	Op  string `json:"op"  validate:"omitempty,oneof=eq ne gt lt gte lte in notin like notlike"`
+	Op  string `json:"op"  validate:"omitempty,oneof=eq ne gt lt gte lte in notin like notlike in_cidr notin_cidr between is_null not_null prefix suffix"`
 	Val string `json:"val" validate:"lte=100"`
 }
 
-func (f *filter) UnmarshalBind(raw string) error {
+func (f *Filter) UnmarshalBind(raw string) error {
 	data, err := url.QueryUnescape(raw)
 	if err != nil {
 		return err
 	}
 	return json.Unmarshal([]byte(data), f)
 }
+
+// Cond 条件树，与 Filters 之间为 AND。
+//
+// Logic 为空代表叶子节点，即单个过滤条件（Col Op Val）；否则为分组节点，
+// Conds 之间按照 Logic 组合：and 并且、or 或者、not 对 Conds 整体（AND）取反。
+// 例如：level = critical OR (risk_type = 暴力破解 AND status = 未处理)
+//
+//	{"logic": "or", "conds": [
+//	  {"col": "level", "op": "eq", "val": "critical"},
+//	  {"logic": "and", "conds": [
+//	    {"col": "risk_type", "op": "eq", "val": "暴力破解"},
+//	    {"col": "status", "op": "eq", "val": "未处理"}
+//	  ]}
+//	]}
+type Cond struct {
+	Logic string  `json:"logic,omitempty" validate:"omitempty,oneof=and or not"`
+	Conds []*Cond `json:"conds,omitempty" validate:"lte=50,dive"`
+	Col   string  `json:"col,omitempty"   validate:"lte=50"`
+	Op    string  `json:"op,omitempty"    validate:"omitempty,oneof=eq ne gt lt gte lte in notin like notlike in_cidr notin_cidr between is_null not_null prefix suffix"`
+	Val   string  `json:"val,omitempty"   validate:"lte=100"`
+}
+
+func (c *Cond) UnmarshalBind(raw string) error {
+	data, err := url.QueryUnescape(raw)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal([]byte(data), c)
+}
+
+// Aggregate 聚合条件，Group 与 Date 至少指定一个，如每天每种风险类型的数量：
+//
+//	{"group": "risk_type", "date": "occur_at", "interval": "day", "func": "count"}
+type Aggregate struct {
+	Group    string `json:"group"    validate:"lte=50"`                                               // 分组列
+	Date     string `json:"date"     validate:"lte=50"`                                               // 按照时间分桶的列
+	Interval string `json:"interval" validate:"omitempty,oneof=hour day week month"`                  // 分桶间隔，默认 day
+	Func     string `json:"func"     validate:"omitempty,oneof=count count_distinct min max sum avg"` // 聚合函数，默认 count
+	Col      string `json:"col"      validate:"lte=50"`                                               // 聚合的列，count 时可以为空
+}
+
+func (a *Aggregate) UnmarshalBind(raw string) error {
+	data, err := url.QueryUnescape(raw)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal([]byte(data), a)
+}
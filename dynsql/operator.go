@@ -17,8 +17,27 @@ var (
 	NotIn   = &operator{opName: "NOT IN", opSymbol: "notin"}
 	Like    = &operator{opName: "LIKE", opSymbol: "like"}
 	NotLike = &operator{opName: "NOT LIKE", opSymbol: "notlike"}
+
+	// InCIDR 与 NotInCIDR 只适用于 IPColumn，值为网段（如：10.0.0.0/8）或单个 IP。
+	InCIDR    = &operator{opName: "属于网段", opSymbol: "in_cidr"}
+	NotInCIDR = &operator{opName: "不属于网段", opSymbol: "notin_cidr"}
+
+	// Between 值为以分隔符隔开的两个值（包含两端），如：10|20。
+	Between = &operator{opName: "介于", opSymbol: "between"}
+
+	// IsNull 与 NotNull 不需要值。
+	IsNull  = &operator{opName: "为空", opSymbol: "is_null"}
+	NotNull = &operator{opName: "不为空", opSymbol: "not_null"}
+
+	// Prefix 与 Suffix 匹配开头与结尾，输入中的通配符会被转义。
+	Prefix = &operator{opName: "开头是", opSymbol: "prefix"}
+	Suffix = &operator{opName: "结尾是", opSymbol: "suffix"}
 )
 
+// EnumOperators 枚举类型的列常用的运算符，列默认支持全部运算符，需要收窄时
+// 通过 Operators(EnumOperators) 显式指定。
+var EnumOperators = []Operator{Eq, Ne, In, NotIn}
+
 type Operator interface {
 	name() string
 	value() string
@@ -35,6 +54,12 @@ func (op *operator) name() string  { return op.opName }
 func (op *operator) value() string { return op.opSymbol }
 
 func (op *operator) expr(col string, values ...any) clause.Expression {
+	switch op {
+	case IsNull:
+		return clause.Eq{Column: col, Value: nil}
+	case NotNull:
+		return clause.Neq{Column: col, Value: nil}
+	}
 	if len(values) == 0 {
 		return nil
 	}
@@ -51,7 +76,7 @@ func (op *operator) expr(col string, values ...any) clause.Expression {
 	case Lt:
 		return clause.Lt{Column: col, Value: value}
 	case Gte:
-		return clause.Gte{Column: Gte, Value: value}
+		return clause.Gte{Column: col, Value: value}
 	case Lte:
 		return clause.Lte{Column: col, Value: value}
 	case In:
@@ -59,17 +84,32 @@ func (op *operator) expr(col string, values ...any) clause.Expression {
 	case NotIn:
 		in := clause.IN{Column: col, Values: values}
 		return clause.Not(in)
-	case Like, NotLike:
-		if str, ok := value.(string); ok {
-			// 如果输入包含了通配符，就不再拼接通配符，MySQL 通配符参考以下链接：
-			// https://dev.mysql.com/doc/refman/8.0/en/pattern-matching.html
+	case Between:
+		if len(values) != 2 {
+			return nil
+		}
+		return clause.Expr{SQL: "? BETWEEN ? AND ?", Vars: []any{clause.Column{Name: col}, values[0], values[1]}}
+	case Like, NotLike, Prefix, Suffix:
+		str, ok := value.(string)
+		if !ok {
+			return nil
+		}
+		// MySQL 通配符参考以下链接：
+		// https://dev.mysql.com/doc/refman/8.0/en/pattern-matching.html
+		switch op {
+		case Prefix: // 开头与结尾匹配时输入中的通配符按照普通字符匹配
+			str = EscapeLike(str) + "%"
+		case Suffix:
+			str = "%" + EscapeLike(str)
+		default:
+			// 如果输入包含了通配符，就不再拼接通配符
 			if !strings.Contains(str, "%") &&
 				!strings.Contains(str, "_") {
-				value = "%" + str + "%"
+				str = "%" + str + "%"
 			}
 		}
 
-		like := clause.Like{Column: col, Value: value}
+		like := clause.Like{Column: col, Value: str}
 		if op == NotLike {
 			return clause.Not(like)
 		}
@@ -78,6 +118,11 @@ func (op *operator) expr(col string, values ...any) clause.Expression {
 	return nil
 }
 
+// EscapeLike 转义 LIKE 中的通配符，MySQL 默认的转义字符为 \
+func EscapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
+
 func (op *operator) schema() *operatorSchema {
 	return &operatorSchema{
 		Name: op.opName,
@@ -0,0 +1,223 @@
+package dynsql
+
+import (
+	"database/sql/driver"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ErrCursor 分页游标无效，或者与本次查询的排序条件不一致。
+var ErrCursor = errors.New("分页游标无效")
+
+// cursor 分页游标，记录了上一页最后一条数据排序列的值。
+type cursor struct {
+	Orders string    `json:"o"` // 排序条件签名，防止游标被用在其它排序上
+	Values []*string `json:"v"` // 排序列的值，nil 代表 NULL
+}
+
+// pageOrders 分页时的排序条件：在最后追加 keyset 列保证顺序唯一，方向与最后
+// 一个排序条件相同。
+func (sc *scope) pageOrders() []*OrderBy {
+	orders := make([]*OrderBy, 0, len(sc.orders)+1)
+	var desc bool
+	for _, o := range sc.orders {
+		if o.Col == sc.keyset {
+			return append(orders, sc.orders...)
+		}
+		desc = o.Desc
+	}
+	orders = append(orders, sc.orders...)
+
+	return append(orders, &OrderBy{Col: sc.keyset, Desc: desc})
+}
+
+func (sc *scope) Page(db *gorm.DB, dest any) (string, error) {
+	rv := reflect.ValueOf(dest)
+	if rv.Kind() != reflect.Pointer || rv.Elem().Kind() != reflect.Slice {
+		return "", errors.New("dest 必须是切片的指针")
+	}
+
+	db = sc.Where(db)
+	if sc.after != nil {
+		db = db.Where(sc.after)
+	}
+	orders := sc.pageOrders()
+	for _, o := range orders {
+		column := clause.Column{Name: o.Col, Raw: true}
+		db = db.Order(clause.OrderByColumn{Column: column, Desc: o.Desc})
+	}
+	// 多查一条用于判断是否还有下一页
+	tx := db.Limit(sc.size + 1).Find(dest)
+	if err := tx.Error; err != nil {
+		return "", err
+	}
+
+	slice := rv.Elem()
+	if slice.Len() <= sc.size {
+		return "", nil
+	}
+	slice.Set(slice.Slice(0, sc.size))
+	last := reflect.Indirect(slice.Index(sc.size - 1))
+
+	sch := tx.Statement.Schema
+	if sch == nil {
+		return "", errors.New("无法解析 dest 的表结构")
+	}
+	values := make([]*string, 0, len(orders))
+	for _, o := range orders {
+		name := o.Col
+		if i := strings.LastIndexByte(name, '.'); i >= 0 {
+			name = name[i+1:]
+		}
+		field := sch.LookUpField(name)
+		if field == nil {
+			return "", &Error{name: "分页字段", value: o.Col}
+		}
+		val, _ := field.ValueOf(tx.Statement.Context, last)
+		values = append(values, formatValue(val))
+	}
+
+	return encodeCursor(orders, values)
+}
+
+// after 解析游标，生成取下一页数据的条件：
+//
+//	(c1 > v1) OR (c1 = v1 AND c2 > v2) OR (c1 = v1 AND c2 = v2 AND id > v3)
+//
+// MySQL 升序时 NULL 排在最前面，降序时排在最后面，值为 NULL 时按照这个顺序
+// 生成 IS NULL / IS NOT NULL 条件。
+func (tbl *tableEnv) after(orders []*OrderBy, str string) (clause.Expression, error) {
+	data, err := base64.RawURLEncoding.DecodeString(str)
+	if err != nil {
+		return nil, ErrCursor
+	}
+	var cur cursor
+	if err = json.Unmarshal(data, &cur); err != nil {
+		return nil, ErrCursor
+	}
+	if cur.Orders != orderSignature(orders) || len(cur.Values) != len(orders) {
+		return nil, ErrCursor
+	}
+
+	values := make([]any, 0, len(orders))
+	for i, o := range orders {
+		col := tbl.orderMap[o.Col]
+		if o.Col == tbl.keyset.columnName() {
+			col = tbl.keyset
+		}
+		if col == nil {
+			return nil, &Error{name: "排序条件", value: o.Col}
+		}
+		str := cur.Values[i]
+		if str == nil {
+			values = append(values, nil)
+			continue
+		}
+		value, err := storedValue(col.columnType(), *str)
+		if err != nil {
+			return nil, ErrCursor
+		}
+		values = append(values, value)
+	}
+
+	ors := make([]clause.Expression, 0, len(orders))
+	for i, o := range orders {
+		next := nextValue(o, values[i])
+		if next == nil { // 降序时 NULL 之后没有数据
+			continue
+		}
+		ands := make([]clause.Expression, 0, i+1)
+		for j := 0; j < i; j++ {
+			// Value 为 nil 时生成 IS NULL
+			ands = append(ands, clause.Eq{Column: orders[j].Col, Value: values[j]})
+		}
+		ands = append(ands, next)
+		ors = append(ors, clause.And(ands...))
+	}
+	if len(ors) == 0 {
+		return clause.Expr{SQL: "1 = 0"}, nil
+	}
+
+	return clause.Or(ors...), nil
+}
+
+// nextValue 排在 value 之后的条件，返回 nil 代表没有。
+func nextValue(o *OrderBy, value any) clause.Expression {
+	switch {
+	case value == nil && o.Desc:
+		return nil
+	case value == nil:
+		return clause.Neq{Column: o.Col, Value: nil}
+	case o.Desc:
+		return clause.Or(clause.Lt{Column: o.Col, Value: value}, clause.Eq{Column: o.Col, Value: nil})
+	default:
+		return clause.Gt{Column: o.Col, Value: value}
+	}
+}
+
+// storedValue 将数据库中保存的值（字符串形式）转为列的类型，需要特殊 SQL
+// 的类型（如：IP、版本号）保持原始字符串。
+func storedValue(tp columnTyper, str string) (any, error) {
+	if _, ok := tp.(columnExprer); ok {
+		return str, nil
+	}
+	return tp.cast(str)
+}
+
+func encodeCursor(orders []*OrderBy, values []*string) (string, error) {
+	data, err := json.Marshal(&cursor{Orders: orderSignature(orders), Values: values})
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+func orderSignature(orders []*OrderBy) string {
+	sn := make([]string, 0, len(orders))
+	for _, o := range orders {
+		sn = append(sn, o.Col+":"+strconv.FormatBool(o.Desc))
+	}
+	return strings.Join(sn, ",")
+}
+
+// formatValue 将结构体字段的值格式化为列类型 cast 可以解析的字符串，NULL 返回 nil。
+func formatValue(v any) *string {
+	if vr, ok := v.(driver.Valuer); ok {
+		if dv, err := vr.Value(); err == nil {
+			v = dv
+		}
+	}
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Pointer {
+		if rv.IsNil() {
+			return nil
+		}
+		rv = rv.Elem()
+	}
+	if !rv.IsValid() {
+		return nil
+	}
+
+	var str string
+	switch val := rv.Interface().(type) {
+	case time.Time:
+		str = val.Format(time.RFC3339Nano)
+	case time.Duration:
+		str = strconv.FormatInt(int64(val), 10)
+	case []byte:
+		str = string(val)
+	default:
+		str = fmt.Sprint(val)
+	}
+
+	return &str
+}
@@ -1,14 +1,32 @@
 package dynsql
 
 type Schema struct {
-	Filters columnSchemas `json:"filters"`
-	Groups  nameSchemas   `json:"groups"`
-	Orders  nameSchemas   `json:"orders"`
+	Filters columnSchemas    `json:"filters"`
+	Groups  nameSchemas      `json:"groups"`
+	Orders  nameSchemas      `json:"orders"`
+	Where   *conditionSchema `json:"where"` // 条件树，为空代表不支持
+
+	Aggregate *aggregateSchema `json:"aggregate"` // 聚合查询
+}
+
+// aggregateSchema 聚合查询支持的函数、时间分桶间隔以及可以聚合的数值列，
+// 分组列为 Groups，其中 time 类型的列可以按照时间分桶。
+type aggregateSchema struct {
+	Funcs     []*operatorSchema `json:"funcs"`
+	Intervals []*operatorSchema `json:"intervals"`
+	Metrics   nameSchemas       `json:"metrics"`
+}
+
+// conditionSchema 条件树支持的逻辑运算符与最大嵌套层数，前端据此渲染分组。
+type conditionSchema struct {
+	Logics []*operatorSchema `json:"logics"`
+	Depth  int               `json:"depth"`
 }
 
 type nameSchema struct {
 	Col  string `json:"col"`
 	Name string `json:"name"`
+	Type string `json:"type"`
 }
 
 type nameSchemas []*nameSchema
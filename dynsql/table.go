@@ -1,7 +1,11 @@
+// Package dynsql 列表页通用的动态查询：按照白名单（Column）解释前端传入的
+// 过滤条件、条件树、分组与排序，并导出 Schema 供前端渲染查询构建器。
+// 旧的 opencond 已经合并到本包，只保留了兼容旧输入格式的适配层。
 package dynsql
 
 import (
 	"fmt"
+	"strings"
 
 	"gorm.io/gorm"
 	"gorm.io/gorm/clause"
@@ -26,8 +30,12 @@ func (e *Error) Error() string {
 
 type tableEnv struct {
 	filterMap map[string]Column
-	groupMap  map[string]struct{}
-	orderMap  map[string]struct{}
+	groupMap  map[string]Column
+	orderMap  map[string]Column
+	metricMap map[string]Column
+	depth     int    // 条件树最多嵌套的分组层数，0 代表不支持条件树
+	sep       string // IN / NOT IN 多个值之间的分隔符
+	keyset    Column // 分页游标使用的唯一列
 	schema    Schema
 }
 
@@ -36,19 +44,29 @@ func (tbl *tableEnv) Schema() Schema {
 }
 
 func (tbl *tableEnv) Inter(input Input) (Scope, error) {
-	ret := new(scope)
-	if input.empty() || (len(tbl.orderMap) == 0 && len(tbl.filterMap) == 0 && len(tbl.groupMap) == 0) {
+	ret := &scope{keyset: tbl.keyset.columnName(), size: input.Size}
+	if ret.size <= 0 {
+		ret.size = 20
+	} else if ret.size > 1000 {
+		ret.size = 1000
+	}
+	if input.empty() || (len(tbl.orderMap) == 0 && len(tbl.filterMap) == 0 && len(tbl.groupMap) == 0 && input.Cursor == "") {
 		return ret, nil
 	}
 
-	filters, group, order := input.Filters, input.Group, input.Order
+	filters, group := input.Filters, input.Group
 	items := make([]clause.Expression, 0, len(filters))
 	for _, f := range filters {
-		col, ok := tbl.filterMap[f.Col]
-		if !ok {
-			return nil, &Error{name: "列名", value: f.Col}
+		item, err := tbl.filter(f.Col, f.Op, f.Val)
+		if err != nil {
+			return nil, err
+		}
+		if item != nil {
+			items = append(items, item)
 		}
-		item, err := col.inter(f.Op, f.Val)
+	}
+	if input.Where != nil {
+		item, err := tbl.cond(input.Where, 1)
 		if err != nil {
 			return nil, err
 		}
@@ -60,12 +78,28 @@ func (tbl *tableEnv) Inter(input Input) (Scope, error) {
 		ret.where = clause.And(items...)
 	}
 
-	ret.desc = input.Desc
-	if order != "" && len(tbl.orderMap) != 0 {
-		if _, exist := tbl.orderMap[order]; !exist {
-			return nil, &Error{name: "排序条件", value: order}
+	orders := input.Orders
+	if len(orders) == 0 && input.Order != "" {
+		orders = []*OrderBy{{Col: input.Order, Desc: input.Desc}}
+	}
+	if len(orders) > 5 {
+		return nil, fmt.Errorf("排序条件不能超过 5 个")
+	}
+	for _, o := range orders {
+		if len(tbl.orderMap) == 0 {
+			break
 		}
-		ret.orderBy = order
+		if _, exist := tbl.orderMap[o.Col]; !exist {
+			return nil, &Error{name: "排序条件", value: o.Col}
+		}
+		ret.orders = append(ret.orders, &OrderBy{Col: o.Col, Desc: o.Desc})
+	}
+	if input.Cursor != "" {
+		after, err := tbl.after(ret.pageOrders(), input.Cursor)
+		if err != nil {
+			return nil, err
+		}
+		ret.after = after
 	}
 
 	if group != "" && len(tbl.groupMap) != 0 {
@@ -75,21 +109,104 @@ func (tbl *tableEnv) Inter(input Input) (Scope, error) {
 		ret.groupBy = group
 	}
 
+	if input.Agg != nil {
+		agg, err := tbl.aggregate(input.Agg)
+		if err != nil {
+			return nil, err
+		}
+		ret.agg = agg
+	}
+
 	return ret, nil
 }
 
+func (tbl *tableEnv) filter(column, op, val string) (clause.Expression, error) {
+	col, ok := tbl.filterMap[column]
+	if !ok {
+		return nil, &Error{name: "列名", value: column}
+	}
+	return col.inter(op, val, tbl.sep)
+}
+
+// cond 解析条件树，level 为当前节点所在的层数（从 1 开始）。
+func (tbl *tableEnv) cond(c *Cond, level int) (clause.Expression, error) {
+	if c.Logic == "" {
+		if len(c.Conds) != 0 { // 避免子条件被静默忽略
+			return nil, fmt.Errorf("条件分组缺少逻辑运算符")
+		}
+		return tbl.filter(c.Col, c.Op, c.Val)
+	}
+	if c.Col != "" || c.Op != "" {
+		return nil, fmt.Errorf("条件分组不能同时指定列与运算符")
+	}
+	if tbl.depth <= 0 {
+		return nil, fmt.Errorf("不支持条件分组")
+	}
+	if level > tbl.depth {
+		return nil, fmt.Errorf("条件分组最多嵌套 %d 层", tbl.depth)
+	}
+
+	items := make([]clause.Expression, 0, len(c.Conds))
+	for _, sub := range c.Conds {
+		if sub == nil {
+			continue
+		}
+		item, err := tbl.cond(sub, level+1)
+		if err != nil {
+			return nil, err
+		}
+		if item != nil {
+			items = append(items, item)
+		}
+	}
+	if len(items) == 0 { // 空分组忽略
+		return nil, nil
+	}
+
+	switch strings.ToLower(c.Logic) {
+	case "and":
+		return clause.And(items...), nil
+	case "or":
+		if len(items) == 1 {
+			return items[0], nil
+		}
+		return clause.Or(items...), nil
+	case "not":
+		return clause.Not(clause.And(items...)), nil
+	default:
+		return nil, &Error{name: "逻辑运算符", value: c.Logic}
+	}
+}
+
 type Scope interface {
+	// Condition 过滤条件表达式，没有过滤条件时为 nil。
+	Condition() clause.Expression
 	Where(*gorm.DB) *gorm.DB
 	GroupBy(*gorm.DB) *gorm.DB
 	OrderBy(*gorm.DB) *gorm.DB
 	GroupColumn() string
+
+	// Page 按照游标（keyset）分页查询，dest 为结构体切片的指针，返回下一页的
+	// 游标，为空代表已经是最后一页。排序条件最后会追加 Keyset 列保证顺序唯一，
+	// 深度翻页也只需要扫描一页的数据。
+	Page(db *gorm.DB, dest any) (next string, err error)
+
+	// Aggregate 按照 Input.Agg 聚合查询，db 需要指定 Model 或 Table。
+	Aggregate(db *gorm.DB) ([]*Bucket, error)
 }
 
 type scope struct {
 	where   clause.Expression
 	groupBy string
-	orderBy string
-	desc    bool
+	orders  []*OrderBy
+	keyset  string            // 分页游标使用的唯一列
+	after   clause.Expression // 游标对应的条件
+	size    int               // 每页条数
+	agg     *aggregate        // 聚合条件
+}
+
+func (sc *scope) Condition() clause.Expression {
+	return sc.where
 }
 
 func (sc *scope) Where(db *gorm.DB) *gorm.DB {
@@ -107,9 +224,9 @@ func (sc *scope) GroupBy(db *gorm.DB) *gorm.DB {
 }
 
 func (sc *scope) OrderBy(db *gorm.DB) *gorm.DB {
-	if o := sc.orderBy; o != "" {
-		column := clause.Column{Name: o, Raw: true}
-		db.Order(clause.OrderByColumn{Column: column, Desc: sc.desc})
+	for _, o := range sc.orders {
+		column := clause.Column{Name: o.Col, Raw: true}
+		db = db.Order(clause.OrderByColumn{Column: column, Desc: o.Desc})
 	}
 	return db
 }
@@ -4,17 +4,34 @@ type TableBuilder interface {
 	Filters(...Column) TableBuilder
 	Groups(...Column) TableBuilder
 	Orders(...Column) TableBuilder
+
+	// Metrics 可以做 min max sum avg 聚合的数值列（IntColumn FloatColumn DurationColumn）。
+	Metrics(...Column) TableBuilder
+
+	// Depth 条件树（Input.Where）最多嵌套的分组层数，默认 3，小于等于 0 代表不支持条件树。
+	Depth(n int) TableBuilder
+
+	// Separator IN / NOT IN 多个值之间的分隔符，默认 |。
+	Separator(sep string) TableBuilder
+
+	// Keyset 分页游标使用的唯一列，排在所有排序条件的最后，保证分页时排序唯一，
+	// 默认为 id。
+	Keyset(Column) TableBuilder
 	Build() Table
 }
 
 func Builder() TableBuilder {
-	return &tableBuilder{}
+	return &tableBuilder{depth: 3, sep: "|", keyset: IntColumn("id", "ID").Build()}
 }
 
 type tableBuilder struct {
 	filters []Column
 	orders  []Column
 	groups  []Column
+	metrics []Column
+	depth   int
+	sep     string
+	keyset  Column
 }
 
 func (tb *tableBuilder) Filters(cs ...Column) TableBuilder {
@@ -32,11 +49,35 @@ func (tb *tableBuilder) Orders(cs ...Column) TableBuilder {
 	return tb
 }
 
+func (tb *tableBuilder) Metrics(cs ...Column) TableBuilder {
+	tb.metrics = append(tb.metrics, cs...)
+	return tb
+}
+
+func (tb *tableBuilder) Depth(n int) TableBuilder {
+	tb.depth = n
+	return tb
+}
+
+func (tb *tableBuilder) Separator(sep string) TableBuilder {
+	if sep != "" {
+		tb.sep = sep
+	}
+	return tb
+}
+
+func (tb *tableBuilder) Keyset(c Column) TableBuilder {
+	if c != nil {
+		tb.keyset = c
+	}
+	return tb
+}
+
 func (tb *tableBuilder) Build() Table {
 	fsz, gsz, osz := len(tb.filters), len(tb.groups), len(tb.orders)
 	filterMap := make(map[string]Column, fsz)
-	groupMap := make(map[string]struct{}, gsz)
-	orderMap := make(map[string]struct{}, osz)
+	groupMap := make(map[string]Column, gsz)
+	orderMap := make(map[string]Column, osz)
 	filters := make(columnSchemas, 0, fsz)
 	groups := make(nameSchemas, 0, gsz)
 	orders := make(nameSchemas, 0, osz)
@@ -48,23 +89,58 @@ func (tb *tableBuilder) Build() Table {
 	}
 	for _, o := range tb.orders {
 		cn := o.columnName()
-		orderMap[cn] = struct{}{}
+		orderMap[cn] = o
 		orders = append(orders, o.nameSchema())
 	}
 	for _, g := range tb.groups {
 		cn := g.columnName()
-		groupMap[cn] = struct{}{}
+		groupMap[cn] = g
 		groups = append(groups, g.nameSchema())
 	}
+	metricMap := make(map[string]Column, len(tb.metrics))
+	metrics := make(nameSchemas, 0, len(tb.metrics))
+	for _, m := range tb.metrics {
+		if !numericType(m.columnType()) {
+			continue
+		}
+		cn := m.columnName()
+		metricMap[cn] = m
+		metrics = append(metrics, m.nameSchema())
+	}
+
+	depth := tb.depth
+	var where *conditionSchema
+	if depth > 0 {
+		where = &conditionSchema{
+			Logics: []*operatorSchema{
+				{Name: "并且", Op: "and"},
+				{Name: "或者", Op: "or"},
+				{Name: "非", Op: "not"},
+			},
+			Depth: depth,
+		}
+	} else {
+		depth = 0
+	}
 
 	return &tableEnv{
 		filterMap: filterMap,
 		groupMap:  groupMap,
 		orderMap:  orderMap,
+		metricMap: metricMap,
+		depth:     depth,
+		sep:       tb.sep,
+		keyset:    tb.keyset,
 		schema: Schema{
 			Filters: filters,
 			Groups:  groups,
 			Orders:  orders,
+			Where:   where,
+			Aggregate: &aggregateSchema{
+				Funcs:     aggFuncSchemas,
+				Intervals: aggIntervalSchemas,
+				Metrics:   metrics,
+			},
 		},
 	}
 }
@@ -0,0 +1,273 @@
+package dynsql
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/vela-ssoc/backend-common/model"
+	"gorm.io/gorm/schema"
+)
+
+var (
+	valuerType   = reflect.TypeOf((*driver.Valuer)(nil)).Elem()
+	timeType     = reflect.TypeOf(time.Time{})
+	durationType = reflect.TypeOf(time.Duration(0))
+	semverType   = reflect.TypeOf(model.Semver(""))
+)
+
+// operatorMap 按照符号查找运算符，用于解析 dynsql 标签中的 ops。
+var operatorMap = func() map[string]Operator {
+	ops := []Operator{
+		Eq, Ne, Gt, Lt, Gte, Lte, In, NotIn, Like, NotLike,
+		InCIDR, NotInCIDR, Between, IsNull, NotNull, Prefix, Suffix,
+	}
+	hm := make(map[string]Operator, len(ops))
+	for _, op := range ops {
+		hm[op.value()] = op
+	}
+	return hm
+}()
+
+// FromModel 根据结构体标签生成 TableBuilder，只有带 dynsql 标签的字段才会
+// 参与查询，列名取自 gorm 标签的 column，没有时按照 gorm 的默认规则生成。
+// 返回的 TableBuilder 可以继续追加列或者修改参数。
+//
+// dynsql 标签的格式与 gorm 相同，以 ; 分隔：
+//
+//	name:风险级别          显示名，默认为列名
+//	type:ip               列类型：string int bool time float ip semver duration，
+//	                      默认根据字段类型推断，实现了 driver.Valuer 的字段默认为 string
+//	enums:1=未处理,2=已处理  枚举值，= 后面为显示名，可以省略
+//	ops:eq,ne,in          可用的运算符，默认为该类型的默认运算符
+//	filter order group metric  可以过滤、排序、分组、聚合，都不写时默认只能过滤
+//	-                     忽略该字段
+//
+// 例如：
+//
+//	Level RiskLevel `gorm:"column:level" dynsql:"name:风险级别;enums:紧急,高危,中危,低危;filter;group"`
+//
+// gorm 标签带有 primaryKey 的整数列会作为分页游标的 Keyset。
+func FromModel(v any) (TableBuilder, error) {
+	typ := reflect.TypeOf(v)
+	for typ != nil && typ.Kind() == reflect.Pointer {
+		typ = typ.Elem()
+	}
+	if typ == nil || typ.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("%T 不是结构体", v)
+	}
+
+	tb := Builder().(*tableBuilder)
+	if err := tb.reflectFields(typ); err != nil {
+		return nil, err
+	}
+
+	return tb, nil
+}
+
+func (tb *tableBuilder) reflectFields(typ reflect.Type) error {
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		tag, ok := field.Tag.Lookup("dynsql")
+		if field.Anonymous && !ok {
+			ft := field.Type
+			if ft.Kind() == reflect.Pointer {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				if err := tb.reflectFields(ft); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+		if !ok || tag == "-" || !field.IsExported() {
+			continue
+		}
+
+		gormTag := schema.ParseTagSetting(field.Tag.Get("gorm"), ";")
+		settings := schema.ParseTagSetting(tag, ";")
+		column := gormTag["COLUMN"]
+		if column == "" {
+			column = schema.NamingStrategy{}.ColumnName("", field.Name)
+		}
+		name := settings["NAME"]
+		if name == "" {
+			name = column
+		}
+
+		col, err := reflectColumn(field, column, name, settings)
+		if err == nil {
+			err = checkOperators(col)
+		}
+		if err != nil {
+			return fmt.Errorf("%s.%s: %w", typ.Name(), field.Name, err)
+		}
+
+		_, filter := settings["FILTER"]
+		_, order := settings["ORDER"]
+		_, group := settings["GROUP"]
+		_, metric := settings["METRIC"]
+		if !filter && !order && !group && !metric {
+			filter = true
+		}
+		if filter {
+			tb.filters = append(tb.filters, col)
+		}
+		if order {
+			tb.orders = append(tb.orders, col)
+		}
+		if group {
+			tb.groups = append(tb.groups, col)
+		}
+		if metric {
+			tb.metrics = append(tb.metrics, col)
+		}
+		if _, pk := gormTag["PRIMARYKEY"]; pk && col.columnType() == typeInt {
+			tb.keyset = col
+		}
+	}
+
+	return nil
+}
+
+func reflectColumn(field reflect.StructField, column, name string, settings map[string]string) (Column, error) {
+	var ops []Operator
+	if str := settings["OPS"]; str != "" {
+		for _, s := range strings.Split(str, ",") {
+			op, ok := operatorMap[strings.TrimSpace(s)]
+			if !ok {
+				return nil, &Error{name: "运算符", value: s}
+			}
+			ops = append(ops, op)
+		}
+	}
+	enums := parseEnums(settings["ENUMS"])
+
+	tp := settings["TYPE"]
+	if tp == "" {
+		tp = reflectType(field.Type)
+	}
+	switch tp {
+	case "string":
+		cb := StringColumn(column, name).Operators(ops)
+		if len(enums) != 0 {
+			eb := StringEnum()
+			for _, e := range enums {
+				eb.Set(e[0], e[1])
+			}
+			cb.Enums(eb)
+		}
+		return cb.Build(), nil
+	case "int":
+		cb := IntColumn(column, name).Operators(ops)
+		if len(enums) != 0 {
+			eb := IntEnum()
+			for _, e := range enums {
+				n, err := strconv.Atoi(e[0])
+				if err != nil {
+					return nil, &Error{name: "枚举值", value: e[0]}
+				}
+				eb.Set(n, e[1])
+			}
+			cb.Enums(eb)
+		}
+		return cb.Build(), nil
+	case "bool":
+		cb := BoolColumn(column, name).Operators(ops)
+		if len(enums) != 0 {
+			eb := BoolEnum()
+			for _, e := range enums {
+				switch e[0] {
+				case "true":
+					eb.True(e[1])
+				case "false":
+					eb.False(e[1])
+				default:
+					return nil, &Error{name: "枚举值", value: e[0]}
+				}
+			}
+			cb.Enums(eb)
+		}
+		return cb.Build(), nil
+	case "time":
+		return TimeColumn(column, name).Operators(ops).Build(), nil
+	case "float":
+		return FloatColumn(column, name).Operators(ops).Build(), nil
+	case "ip":
+		return IPColumn(column, name).Operators(ops).Build(), nil
+	case "semver":
+		return SemverColumn(column, name).Operators(ops).Build(), nil
+	case "duration":
+		return DurationColumn(column, name).Operators(ops).Build(), nil
+	default:
+		return nil, &Error{name: "列类型", value: tp}
+	}
+}
+
+// checkOperators 检查 ops 标签中的运算符是否适用于列的类型，如：int 列不能使用 like。
+func checkOperators(col Column) error {
+	tc, ok := col.(*tableColumn)
+	if !ok {
+		return nil
+	}
+	for _, op := range tc.ops {
+		if !supportOperator(tc.tp, op) {
+			return fmt.Errorf("%s 类型不支持运算符 %s", tc.tp.name(), op.value())
+		}
+	}
+	return nil
+}
+
+// reflectType 根据字段类型推断列类型
+func reflectType(typ reflect.Type) string {
+	for typ.Kind() == reflect.Pointer {
+		typ = typ.Elem()
+	}
+	switch typ {
+	case timeType:
+		return "time"
+	case durationType:
+		return "duration"
+	case semverType:
+		return "semver"
+	}
+	// 自定义了数据库中的保存形式（如：model.RiskLevel 保存为字符串）
+	if typ.Implements(valuerType) || reflect.PointerTo(typ).Implements(valuerType) {
+		return "string"
+	}
+
+	switch typ.Kind() {
+	case reflect.String:
+		return "string"
+	case reflect.Bool:
+		return "bool"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return "int"
+	case reflect.Float32, reflect.Float64:
+		return "float"
+	default:
+		return typ.Kind().String()
+	}
+}
+
+// parseEnums 解析 1=未处理,2=已处理，省略显示名时与值相同。
+func parseEnums(str string) [][2]string {
+	if str == "" {
+		return nil
+	}
+	sn := strings.Split(str, ",")
+	enums := make([][2]string, 0, len(sn))
+	for _, s := range sn {
+		val, name, found := strings.Cut(strings.TrimSpace(s), "=")
+		if !found {
+			name = val
+		}
+		enums = append(enums, [2]string{val, name})
+	}
+	return enums
+}
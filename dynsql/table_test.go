@@ -0,0 +1,451 @@
+package dynsql
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/vela-ssoc/backend-common/model"
+	"gorm.io/gorm/clause"
+)
+
+// sqlBuilder 将表达式拼接为 SQL，参数统一输出为 ?
+type sqlBuilder struct {
+	strings.Builder
+	vars []any
+}
+
+func (sb *sqlBuilder) WriteQuoted(field any) {
+	switch f := field.(type) {
+	case clause.Column:
+		if f.Raw {
+			sb.WriteString(f.Name)
+		} else {
+			sb.WriteString("`" + f.Name + "`")
+		}
+	default:
+		sb.WriteString(fmt.Sprintf("`%v`", f))
+	}
+}
+
+func (sb *sqlBuilder) AddVar(w clause.Writer, vars ...any) {
+	for i, v := range vars {
+		if i > 0 {
+			_, _ = w.WriteString(",")
+		}
+		switch val := v.(type) {
+		case clause.Expression:
+			val.Build(sb)
+			continue
+		case clause.Column:
+			sb.WriteQuoted(val)
+			continue
+		case []any:
+			_, _ = w.WriteString("(")
+			sb.AddVar(w, val...)
+			_, _ = w.WriteString(")")
+			continue
+		}
+		_, _ = w.WriteString("?")
+		sb.vars = append(sb.vars, v)
+	}
+}
+
+func (sb *sqlBuilder) AddError(err error) error { return err }
+
+func buildSQL(expr clause.Expression) string {
+	sb := new(sqlBuilder)
+	expr.Build(sb)
+	return sb.String()
+}
+
+// filterCase 单个过滤条件期望生成的 SQL 与参数
+type filterCase struct {
+	col, op, val string
+	sql          string
+	vars         []any
+}
+
+// assertFilterSQL 逐个解析过滤条件并检查生成的 SQL 与参数
+func assertFilterSQL(t *testing.T, tbl Table, cases []filterCase) {
+	t.Helper()
+	for _, c := range cases {
+		sc, err := tbl.Inter(Input{Filters: []*Filter{{Col: c.col, Op: c.op, Val: c.val}}})
+		if err != nil {
+			t.Fatalf("%s %s %s: %v", c.col, c.op, c.val, err)
+		}
+		sb := new(sqlBuilder)
+		sc.Condition().Build(sb)
+		if sb.String() != c.sql || fmt.Sprint(sb.vars) != fmt.Sprint(c.vars) {
+			t.Fatalf("%s %s %s: %s %v", c.col, c.op, c.val, sb.String(), sb.vars)
+		}
+	}
+}
+
+// assertFilterError 逐个解析过滤条件，每个都应该返回错误
+func assertFilterError(t *testing.T, tbl Table, filters ...*Filter) {
+	t.Helper()
+	for _, f := range filters {
+		if _, err := tbl.Inter(Input{Filters: []*Filter{f}}); err == nil {
+			t.Fatalf("expected error: %+v", f)
+		}
+	}
+}
+
+func testTable() Table {
+	return Builder().
+		Filters(
+			StringColumn("level", "级别").Build(),
+			StringColumn("risk_type", "风险类型").Build(),
+			StringColumn("status", "状态").Build(),
+			IntColumn("id", "ID").Build(),
+		).
+		Orders(IntColumn("id", "ID").Build()).
+		Depth(2).
+		Build()
+}
+
+func TestInterCond(t *testing.T) {
+	tbl := testTable()
+	input := Input{
+		Filters: []*Filter{{Col: "id", Op: "gt", Val: "10"}},
+		Where: &Cond{Logic: "or", Conds: []*Cond{
+			{Col: "level", Op: "eq", Val: "critical"},
+			{Logic: "and", Conds: []*Cond{
+				{Col: "risk_type", Op: "eq", Val: "暴力破解"},
+				{Col: "status", Op: "eq", Val: "未处理"},
+			}},
+			{Logic: "not", Conds: []*Cond{{Col: "status", Op: "eq", Val: "忽略"}}},
+		}},
+	}
+	sc, err := tbl.Inter(input)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := buildSQL(sc.(*scope).where)
+	want := "(`id` > ? AND (`level` = ? OR (`risk_type` = ? AND `status` = ?) OR `status` <> ?))"
+	if got != want {
+		t.Fatalf("unexpected sql:\n got: %s\nwant: %s", got, want)
+	}
+
+	// 超过嵌套层数
+	input.Where.Conds[1].Conds[0] = &Cond{Logic: "and", Conds: []*Cond{{Col: "level", Op: "eq", Val: "low"}}}
+	if _, err = tbl.Inter(input); err == nil {
+		t.Fatal("expected depth error")
+	}
+
+	for _, where := range []*Cond{
+		// 列名不在白名单中
+		{Logic: "or", Conds: []*Cond{{Col: "password", Op: "eq", Val: "x"}}},
+		// 叶子节点带有子条件
+		{Col: "level", Op: "eq", Val: "low", Conds: []*Cond{{Col: "status", Op: "eq", Val: "忽略"}}},
+		// 分组同时指定了列
+		{Logic: "and", Col: "level", Op: "eq", Val: "low", Conds: []*Cond{{Col: "status", Op: "eq", Val: "忽略"}}},
+	} {
+		input.Where = where
+		if _, err = tbl.Inter(input); err == nil {
+			t.Fatalf("expected error: %+v", where)
+		}
+	}
+
+	if sch := tbl.Schema(); sch.Where == nil || sch.Where.Depth != 2 {
+		t.Fatalf("unexpected schema: %+v", sch.Where)
+	}
+}
+
+func TestColumnDefaults(t *testing.T) {
+	tbl := Builder().
+		Filters(
+			BoolColumn("enable", "启用").Build(),
+			StringColumn("level", "级别").Enums(StringEnum().Set("high", "高危")).Build(),
+			IntColumn("status", "状态").Enums(IntEnum().Set(1, "在线")).Build(),
+			IntColumn("kind", "类型").Operators(EnumOperators).Enums(IntEnum().Set(1, "主机")).Build(),
+		).
+		Build()
+
+	// 未指定运算符与枚举时沿用原有的默认值
+	for _, f := range []*Filter{
+		{Col: "enable", Op: "eq", Val: "1"},
+		{Col: "enable", Op: "ne", Val: "TRUE"},
+		{Col: "level", Op: "like", Val: "high"},
+		{Col: "status", Op: "gt", Val: "1"},
+	} {
+		if _, err := tbl.Inter(Input{Filters: []*Filter{f}}); err != nil {
+			t.Fatalf("%+v: %v", f, err)
+		}
+	}
+	if _, err := tbl.Inter(Input{Filters: []*Filter{{Col: "kind", Op: "gt", Val: "1"}}}); err == nil {
+		t.Fatal("expected operator error")
+	}
+}
+
+func TestColumnTypes(t *testing.T) {
+	tbl := Builder().
+		Filters(
+			IPColumn("inet", "IP").Build(),
+			FloatColumn("total_score", "漏洞总分").Build(),
+			DurationColumn("elapsed", "耗时").Build(),
+			SemverColumn("semver", "版本").Weight("weight").Build(),
+			SemverColumn("edition", "版本").Build(),
+		).
+		Build()
+
+	assertFilterSQL(t, tbl, []filterCase{
+		{"inet", "in_cidr", "10.1.0.0/16", "INET_ATON(`inet`) BETWEEN ? AND ?", []any{uint32(0x0a010000), uint32(0x0a01ffff)}},
+		{"inet", "notin_cidr", "192.168.1.1", "NOT (INET_ATON(`inet`) BETWEEN ? AND ?)", []any{uint32(0xc0a80101), uint32(0xc0a80101)}},
+		{"inet", "eq", "::ffff:10.0.0.1", "`inet` = ?", []any{"10.0.0.1"}},
+		{"total_score", "gte", "7.5", "`total_score` >= ?", []any{7.5}},
+		{"elapsed", "gt", "1.5s", "`elapsed` > ?", []any{int64(1500 * time.Millisecond)}},
+		{"semver", "gte", "1.2.3", "`weight` >= ?", []any{int64(1000002000003)}},
+	})
+
+	sc, err := tbl.Inter(Input{Filters: []*Filter{{Col: "edition", Op: "in", Val: "1.0.0|2.0.0"}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := buildSQL(sc.Condition()); !strings.HasSuffix(got, " IN (?,?)") {
+		t.Fatalf("semver in: %s", got)
+	}
+
+	assertFilterError(t, tbl,
+		&Filter{Col: "inet", Op: "eq", Val: "10.0.0.0/8"},
+		&Filter{Col: "inet", Op: "in_cidr", Val: "10.0.0.300/8"},
+		&Filter{Col: "semver", Op: "gt", Val: "devel"},
+		&Filter{Col: "total_score", Op: "gt", Val: "NaN"},
+	)
+}
+
+func TestOperators(t *testing.T) {
+	now := time.Date(2023, 6, 15, 10, 30, 0, 0, time.Local)
+	timeNow = func() time.Time { return now }
+	defer func() { timeNow = time.Now }()
+
+	tbl := Builder().
+		Filters(
+			StringColumn("name", "名字").Build(),
+			IntColumn("id", "ID").Build(),
+			TimeColumn("occur_at", "发生时间").Build(),
+			TimeColumn("uptime", "上线时间").Build(),
+		).
+		Build()
+
+	assertFilterSQL(t, tbl, []filterCase{
+		{"name", "like", "web", "`name` LIKE ?", []any{"%web%"}},
+		{"name", "notlike", "50%_off", "`name` NOT LIKE ?", []any{"50%_off"}},
+		{"name", "prefix", `a\b`, "`name` LIKE ?", []any{`a\\b%`}},
+		{"name", "suffix", ".exe", "`name` LIKE ?", []any{"%.exe"}},
+		{"id", "between", "10|20", "`id` BETWEEN ? AND ?", []any{int64(10), int64(20)}},
+		{"uptime", "is_null", "", "`uptime` IS NULL", nil},
+		{"uptime", "not_null", "", "`uptime` IS NOT NULL", nil},
+		{"occur_at", "gte", "now-24h", "`occur_at` >= ?", []any{now.Add(-24 * time.Hour)}},
+		{"occur_at", "gte", "today-7d", "`occur_at` >= ?", []any{time.Date(2023, 6, 8, 0, 0, 0, 0, time.Local)}},
+		{"occur_at", "between", "today|now", "`occur_at` BETWEEN ? AND ?", []any{time.Date(2023, 6, 15, 0, 0, 0, 0, time.Local), now}},
+	})
+
+	assertFilterError(t, tbl,
+		&Filter{Col: "id", Op: "between", Val: "10"},
+		&Filter{Col: "occur_at", Op: "gt", Val: "now-7x"},
+		&Filter{Col: "occur_at", Op: "gt", Val: "now7d"},
+	)
+}
+
+func TestPageCursor(t *testing.T) {
+	tbl := Builder().
+		Orders(
+			StringColumn("level", "级别").Build(),
+			TimeColumn("occur_at", "发生时间").Build(),
+		).
+		Build()
+
+	input := Input{Orders: []*OrderBy{{Col: "level"}, {Col: "occur_at", Desc: true}}}
+	sc, err := tbl.Inter(input)
+	if err != nil {
+		t.Fatal(err)
+	}
+	orders := sc.(*scope).pageOrders()
+	if sig := orderSignature(orders); sig != "level:false,occur_at:true,id:true" {
+		t.Fatalf("unexpected orders: %s", sig)
+	}
+
+	at := time.Date(2023, 6, 15, 10, 30, 0, 123, time.UTC)
+	cases := []struct {
+		values []any
+		sql    string
+	}{
+		{
+			[]any{"high", at, int64(42)},
+			"(`level` > ? OR (`level` = ? AND (`occur_at` < ? OR `occur_at` IS NULL)) OR " +
+				"(`level` = ? AND `occur_at` = ? AND (`id` < ? OR `id` IS NULL)))",
+		},
+		{ // 升序时 NULL 之后是所有非 NULL 的值
+			[]any{(*string)(nil), at, int64(42)},
+			"(`level` IS NOT NULL OR (`level` IS NULL AND (`occur_at` < ? OR `occur_at` IS NULL)) OR " +
+				"(`level` IS NULL AND `occur_at` = ? AND (`id` < ? OR `id` IS NULL)))",
+		},
+		{ // 降序时 NULL 之后没有数据
+			[]any{"high", nil, int64(42)},
+			"(`level` > ? OR (`level` = ? AND `occur_at` IS NULL AND (`id` < ? OR `id` IS NULL)))",
+		},
+	}
+	for _, c := range cases {
+		values := make([]*string, 0, len(c.values))
+		for _, v := range c.values {
+			values = append(values, formatValue(v))
+		}
+		input.Cursor, _ = encodeCursor(orders, values)
+		if sc, err = tbl.Inter(input); err != nil {
+			t.Fatal(err)
+		}
+		if got := buildSQL(sc.(*scope).after); got != c.sql {
+			t.Fatalf("unexpected sql:\n got: %s\nwant: %s", got, c.sql)
+		}
+	}
+
+	// 游标中的值要能还原为列的类型，NULL 保持为 NULL
+	for _, c := range []struct {
+		tp    columnTyper
+		value any
+	}{
+		{typeInt, int64(42)},
+		{typeFloat, 7.5},
+		{typeBool, true},
+		{typeTime, at},
+		{typeString, ""},
+		{typeDuration, int64(1500 * time.Millisecond)},
+		{&semverColumnType{}, "1.2.3"},
+	} {
+		str := formatValue(c.value)
+		if str == nil {
+			t.Fatalf("%v: unexpected null", c.value)
+		}
+		got, err := storedValue(c.tp, *str)
+		if err != nil || fmt.Sprint(got) != fmt.Sprint(c.value) {
+			t.Fatalf("%v: %v %v", c.value, got, err)
+		}
+	}
+	for _, v := range []any{nil, (*int64)(nil), sql.NullString{}, sql.NullTime{}} {
+		if str := formatValue(v); str != nil {
+			t.Fatalf("%#v: expected null, got %q", v, *str)
+		}
+	}
+
+	// 排序条件变化后游标失效
+	input.Orders[1].Desc = false
+	if _, err = tbl.Inter(input); err != ErrCursor {
+		t.Fatalf("expected cursor error: %v", err)
+	}
+	input.Cursor = "not-a-cursor"
+	if _, err = tbl.Inter(input); err != ErrCursor {
+		t.Fatalf("expected cursor error: %v", err)
+	}
+}
+
+func TestAggregate(t *testing.T) {
+	tbl := Builder().
+		Groups(
+			StringColumn("risk_type", "风险类型").Build(),
+			TimeColumn("occur_at", "发生时间").Build(),
+		).
+		Metrics(
+			FloatColumn("score", "分数").Build(),
+			StringColumn("subject", "主题").Build(), // 不是数值列，忽略
+		).
+		Build()
+
+	sc, err := tbl.Inter(Input{Agg: &Aggregate{Group: "risk_type", Date: "occur_at", Interval: "week"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := buildSQL(sc.(*scope).agg.selectExpr())
+	want := "`risk_type` AS `key`, DATE_FORMAT(DATE_SUB(`occur_at`, INTERVAL WEEKDAY(`occur_at`) DAY), " +
+		"'%Y-%m-%d 00:00:00') AS `bucket`, COUNT(*) AS `value`"
+	if got != want {
+		t.Fatalf("unexpected sql:\n got: %s\nwant: %s", got, want)
+	}
+
+	if sc, err = tbl.Inter(Input{Agg: &Aggregate{Group: "risk_type", Func: "avg", Col: "score"}}); err != nil {
+		t.Fatal(err)
+	}
+	if got = buildSQL(sc.(*scope).agg.selectExpr()); got != "`risk_type` AS `key`, NULL AS `bucket`, AVG(`score`) AS `value`" {
+		t.Fatalf("unexpected sql: %s", got)
+	}
+
+	for _, agg := range []*Aggregate{
+		{},
+		{Group: "level"},
+		{Date: "risk_type"},
+		{Group: "risk_type", Func: "sum", Col: "subject"},
+		{Group: "risk_type", Interval: "year", Date: "occur_at"},
+	} {
+		if _, err = tbl.Inter(Input{Agg: agg}); err == nil {
+			t.Fatalf("expected error: %+v", agg)
+		}
+	}
+	if sch := tbl.Schema().Aggregate; len(sch.Metrics) != 1 {
+		t.Fatalf("unexpected metrics: %v", sch.Metrics)
+	}
+}
+
+func TestFromModel(t *testing.T) {
+	tb, err := FromModel(&model.Risk{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tbl := tb.Build()
+	sch := tbl.Schema()
+	if len(sch.Orders) != 3 || len(sch.Groups) != 8 {
+		t.Fatalf("unexpected schema: orders %d groups %d", len(sch.Orders), len(sch.Groups))
+	}
+	for _, f := range sch.Filters {
+		if f.Col == "secret" {
+			t.Fatal("secret should be skipped")
+		}
+	}
+
+	sc, err := tbl.Inter(Input{Filters: []*Filter{
+		{Col: "level", Op: "eq", Val: "高危"},
+		{Col: "status", Op: "in", Val: "1|2"},
+		{Col: "remote_ip", Op: "in_cidr", Val: "10.0.0.0/8"},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := buildSQL(sc.Condition())
+	want := "(`level` = ? AND `status` IN (?,?) AND (INET_ATON(`remote_ip`) BETWEEN ? AND ?))"
+	if got != want {
+		t.Fatalf("unexpected sql:\n got: %s\nwant: %s", got, want)
+	}
+
+	assertFilterError(t, tbl,
+		&Filter{Col: "level", Op: "eq", Val: "未知"},
+		&Filter{Col: "status", Op: "like", Val: "1"},
+		&Filter{Col: "secret", Op: "eq", Val: "x"},
+	)
+
+	// 未知的运算符，或者运算符不适用于列的类型
+	for _, v := range []any{
+		struct {
+			Name string `dynsql:"ops:eq,unknown"`
+		}{},
+		struct {
+			Name string `dynsql:"ops:in_cidr"`
+		}{},
+		struct {
+			Port int `dynsql:"ops:like"`
+		}{},
+	} {
+		if _, err = FromModel(v); err == nil {
+			t.Fatalf("expected operator error: %T", v)
+		}
+	}
+	// 手动构建的列同样不能静默忽略过滤条件
+	tbl = Builder().Filters(StringColumn("name", "名字").Operators([]Operator{InCIDR}).Build()).Build()
+	if _, err = tbl.Inter(Input{Filters: []*Filter{{Col: "name", Op: "in_cidr", Val: "10.0.0.0/8"}}}); err == nil {
+		t.Fatal("expected unsupported operator error")
+	}
+	if _, err = FromModel(1); err == nil {
+		t.Fatal("expected struct error")
+	}
+}
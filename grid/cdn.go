@@ -1,7 +1,10 @@
 package grid
 
 import (
+	"container/list"
+	"context"
 	"database/sql"
+	"errors"
 	"io"
 	"io/fs"
 	"os"
@@ -12,28 +15,77 @@ import (
 	"time"
 )
 
-// NewCDN 带本地缓存的
-func NewCDN(db *sql.DB, dir string, min int64) FS {
-	gfs := NewFS(db)
+// CDNOption CDN 缓存参数
+type CDNOption func(*cdn)
+
+// WithQuota 缓存目录的磁盘配额（字节），超出后按照 LRU 淘汰最久未访问的
+// 缓存文件，小于等于 0 代表不限制。
+func WithQuota(n int64) CDNOption {
+	return func(cn *cdn) {
+		cn.quota = n
+	}
+}
+
+// WithCDNStore 指定分片存储后端，见 WithStore。
+func WithCDNStore(st Store) CDNOption {
+	return WithFSOption(WithStore(st))
+}
+
+// WithFSOption 底层 FS 的参数，如：WithKeyring、WithWriteOptions。
+func WithFSOption(opts ...Option) CDNOption {
+	return func(cn *cdn) {
+		cn.opts = append(cn.opts, opts...)
+	}
+}
+
+// NewCDN 带本地缓存的 FS，缓存文件与索引保存在 dir 下的 grid-cdn 子目录中，
+// 重启后会重新加载并与 grid_file.sha1 比对，不一致或不在索引中的缓存文件
+// 会被清理，dir 中的其它文件不受影响。dir 为空时默认为系统临时目录。
+func NewCDN(db *sql.DB, dir string, min int64, opts ...CDNOption) FS {
 	if dir == "" {
 		dir = os.TempDir()
 	}
+	dir = filepath.Join(dir, cdnCacheDir)
+	_ = os.MkdirAll(dir, 0o755)
 	files := make(map[int64]*cdnFile, 64)
 
-	return &cdn{gfs: gfs, dir: dir, min: min, files: files}
+	cn := &cdn{db: db, dir: dir, min: min, files: files, lru: list.New()}
+	for _, fn := range opts {
+		fn(cn)
+	}
+	cn.gfs = NewFS(db, cn.opts...)
+	cn.reload()
+
+	return cn
 }
 
+var (
+	// errCacheAborted 缓存任务被取消（如：文件被删除或缓存被淘汰）
+	errCacheAborted = errors.New("grid: cdn caching aborted")
+
+	// errCacheFallback 缓存失败，已切换为数据库读取
+	errCacheFallback = errors.New("grid: cdn caching fallback")
+)
+
 // cdn 带文件缓存的 FS 管理器
 type cdn struct {
 	gfs   FS                 // 底层文件管理器
+	db    *sql.DB            // 数据库连接，用于重启后校验缓存
+	opts  []Option           // 底层 FS 的参数
 	dir   string             // CDN 文件缓存的目录
 	min   int64              // filesize 小于或等于 min 时不会经过 CDN 缓存
+	quota int64              // 磁盘配额
 	mutex sync.Mutex         // files lock
 	files map[int64]*cdnFile // cdn 缓存文件映射
+	lru   *list.List         // 已缓存完毕的文件，越靠前越是最近访问的
+	used  int64              // 已占用（含正在缓存）的磁盘空间
 }
 
 // Open implement fs.FS
 func (cn *cdn) Open(id string) (fs.File, error) {
+	if id == "." {
+		return &rootDir{gfs: cn}, nil
+	}
 	fid, err := strconv.ParseInt(id, 10, 64)
 	if err != nil {
 		return nil, fs.ErrInvalid
@@ -44,8 +96,13 @@ func (cn *cdn) Open(id string) (fs.File, error) {
 
 // OpenID 通过 ID 打开文件
 func (cn *cdn) OpenID(id int64) (File, error) {
+	return cn.OpenIDContext(context.Background(), id)
+}
+
+// OpenIDContext 通过 ID 打开文件，后台缓存任务不受 ctx 影响。
+func (cn *cdn) OpenIDContext(ctx context.Context, id int64) (File, error) {
 	// 先查询数据库
-	fl, err := cn.gfs.OpenID(id)
+	fl, err := cn.gfs.OpenIDContext(ctx, id)
 	if err != nil {
 		return nil, err
 	}
@@ -55,7 +112,8 @@ func (cn *cdn) OpenID(id int64) (File, error) {
 		return nil, fs.ErrPermission
 	}
 
-	if gfl.filesize <= cn.min {
+	// 加密的文件不缓存到本地磁盘，避免明文落盘
+	if gfl.filesize <= cn.min || gfl.keyID != "" {
 		return gfl, nil
 	}
 
@@ -73,49 +131,170 @@ func (cn *cdn) Write(r io.Reader, name string) (File, error) {
 	return cn.gfs.Write(r, name)
 }
 
+// WriteWith 保存文件并指定分片的压缩与加密方式
+func (cn *cdn) WriteWith(r io.Reader, name string, opt WriteOptions) (File, error) {
+	return cn.gfs.WriteWith(r, name, opt)
+}
+
+// WriteContext 保存文件，ctx 取消后停止写入
+func (cn *cdn) WriteContext(ctx context.Context, r io.Reader, name string, opt *WriteOptions) (File, error) {
+	return cn.gfs.WriteContext(ctx, r, name, opt)
+}
+
+// CreateUpload 创建上传会话
+func (cn *cdn) CreateUpload(name string, size int64, checksum string) (*UploadSession, error) {
+	return cn.gfs.CreateUpload(name, size, checksum)
+}
+
+// UploadPart 上传分片
+func (cn *cdn) UploadPart(id, serial int64, data []byte, checksum string) error {
+	return cn.gfs.UploadPart(id, serial, data, checksum)
+}
+
+// MissingParts 查询缺失的分片
+func (cn *cdn) MissingParts(id int64) ([]int64, error) {
+	return cn.gfs.MissingParts(id)
+}
+
+// CommitUpload 提交上传会话
+func (cn *cdn) CommitUpload(id int64) (File, error) {
+	return cn.gfs.CommitUpload(id)
+}
+
+// AbortUpload 放弃上传会话
+func (cn *cdn) AbortUpload(id int64) error {
+	return cn.gfs.AbortUpload(id)
+}
+
+// StatChecksum 通过 SHA-1 查询文件
+func (cn *cdn) StatChecksum(sum string) (File, error) {
+	return cn.gfs.StatChecksum(sum)
+}
+
+// Scrub 存储维护
+func (cn *cdn) Scrub(ctx context.Context, opt ScrubOptions) (*ScrubReport, error) {
+	return cn.gfs.Scrub(ctx, opt)
+}
+
+// ReadDir 文件列表
+func (cn *cdn) ReadDir(name string) ([]fs.DirEntry, error) {
+	return cn.gfs.ReadDir(name)
+}
+
+// Find 查询文件
+func (cn *cdn) Find(ctx context.Context, q Query) (*FileList, error) {
+	return cn.gfs.Find(ctx, q)
+}
+
+// Meta 查询文件元数据
+func (cn *cdn) Meta(id int64) (map[string]string, error) {
+	return cn.gfs.Meta(id)
+}
+
+// SetMeta 设置文件元数据
+func (cn *cdn) SetMeta(id int64, meta map[string]string) error {
+	return cn.gfs.SetMeta(id, meta)
+}
+
+// Link 引用已存在的内容创建文件
+func (cn *cdn) Link(sum, name string) (File, error) {
+	return cn.gfs.Link(sum, name)
+}
+
 func (cn *cdn) fromCDN(mfl *file) (File, error) {
 	cn.mutex.Lock()
 	defer cn.mutex.Unlock()
 
 	fileID := mfl.id
 	cf, ok := cn.files[fileID]
+	if ok && cf.sha1 != mfl.sha1 {
+		// 数据库中的文件已经变化，缓存失效
+		cn.evictLocked(cf)
+		ok = false
+	}
 	if ok {
 		// 如果 CDN 本地文件已经缓存完毕，就直接读取本地磁盘的文件，
 		// 不再从数据库下载。
 		if cf.done.Load() {
-			return cf.Open()
+			if wf, err := cf.Open(mfl); err == nil {
+				cf.access = time.Now()
+				cn.lru.MoveToFront(cf.elem)
+				return wf, nil
+			}
+			// 缓存文件被意外删除
+			cn.evictLocked(cf)
+			return mfl, nil
 		}
 
-		// 如果文件已经加入了 CDN 缓存，但是还未缓存完毕，那么此次
-		// 继续走数据库下载。
+		// 如果文件正在缓存中，就跟随缓存文件的写入进度读取，不再重复
+		// 从数据库下载，避免升级 agent 时大量请求同时打到数据库。
+		return cf.tail(mfl)
+	}
+
+	// 腾出磁盘空间，空间不够就不缓存
+	if !cn.reserveLocked(mfl.filesize) {
 		return mfl, nil
 	}
 
 	// 如果 CDN 还未创建，就创建 CDN 缓存任务
-	filename := strconv.FormatInt(mfl.id, 10) + "-" + mfl.filename
+	filename := strconv.FormatInt(mfl.id, 10) + "-" + filepath.Base(mfl.filename)
 	disk := filepath.Join(cn.dir, filename)
 	dfl, err := os.OpenFile(disk, os.O_CREATE|os.O_RDWR|os.O_TRUNC, 0o644)
 	if err != nil {
+		cn.used -= mfl.filesize
 		return mfl, nil
 	}
 
-	// 客户端下载的同时进行磁盘缓存
-	cfo := &cdnFile{file: mfl, disk: disk, tmp: dfl}
+	// 后台从数据库下载并写入磁盘缓存，客户端跟随写入进度读取，
+	// 即使第一个客户端中途断开，缓存也会继续完成。
+	cfo := &cdnFile{
+		id:     mfl.id,
+		name:   mfl.filename,
+		size:   mfl.filesize,
+		sha1:   mfl.sha1,
+		disk:   disk,
+		tmp:    dfl,
+		access: time.Now(),
+	}
+	cfo.cond = sync.NewCond(&cfo.mu)
 	cn.files[fileID] = cfo
+	go cn.populate(cfo, mfl.clone())
 
-	ch := cn.newCaching(mfl, cfo, dfl)
-
-	return ch, nil
+	return cfo.tail(mfl)
 }
 
-func (cn *cdn) newCaching(raw *file, cf *cdnFile, disk *os.File) *cdnCaching {
-	teeRead := io.TeeReader(raw, disk)
+// populate 从数据库读取文件写入磁盘缓存
+func (cn *cdn) populate(cf *cdnFile, src *file) {
+	buf := make([]byte, 64*1024)
+	var err error
+	for {
+		n, re := src.Read(buf)
+		if n > 0 {
+			if _, err = cf.tmp.Write(buf[:n]); err != nil {
+				break
+			}
+			if err = cf.progress(int64(n)); err != nil {
+				break
+			}
+		}
+		if re != nil {
+			if re != io.EOF {
+				err = re
+			}
+			break
+		}
+	}
+	_ = cf.tmp.Close()
+	_ = src.Close()
 
-	return &cdnCaching{
-		cdn:     cn,
-		cdnFile: cf,
-		rawFile: raw,
-		teeRead: teeRead,
+	if err == nil {
+		err = cn.finish(cf)
+	}
+	if err != nil {
+		cn.mutex.Lock()
+		cn.evictLocked(cf)
+		cn.mutex.Unlock()
+		cf.fail(err)
 	}
 }
 
@@ -123,27 +302,138 @@ func (cn *cdn) removeID(id int64) {
 	cn.mutex.Lock()
 	defer cn.mutex.Unlock()
 
-	cf, ok := cn.files[id]
-	if ok {
-		delete(cn.files, id)
-		_ = os.Remove(cf.disk)
+	if cf, ok := cn.files[id]; ok {
+		cn.evictLocked(cf)
+	}
+}
+
+// finish 缓存写入完毕，校验大小后加入 LRU 并持久化索引
+func (cn *cdn) finish(cf *cdnFile) error {
+	cn.mutex.Lock()
+	defer cn.mutex.Unlock()
+
+	if cn.files[cf.id] != cf {
+		return errCacheAborted
+	}
+	if err := cf.progress(0); err != nil {
+		return err
+	}
+	cf.mu.Lock()
+	written := cf.written
+	cf.mu.Unlock()
+	if written != cf.size {
+		return io.ErrUnexpectedEOF
+	}
+
+	cf.done.Store(true)
+	cf.elem = cn.lru.PushFront(cf)
+	cn.saveLocked()
+	cf.cond.Broadcast()
+
+	return nil
+}
+
+// reserveLocked 为即将缓存的文件预留磁盘空间，必要时淘汰最久未访问的缓存
+func (cn *cdn) reserveLocked(size int64) bool {
+	if cn.quota > 0 {
+		if size > cn.quota {
+			return false
+		}
+		for cn.used+size > cn.quota {
+			back := cn.lru.Back()
+			if back == nil {
+				return false // 剩余的都是正在缓存的文件
+			}
+			cn.evictLocked(back.Value.(*cdnFile))
+		}
+	}
+	cn.used += size
+
+	return true
+}
+
+// evictLocked 删除缓存文件并释放配额
+func (cn *cdn) evictLocked(cf *cdnFile) {
+	if cn.files[cf.id] != cf {
+		return
+	}
+	delete(cn.files, cf.id)
+	cn.used -= cf.size
+	if cf.elem != nil {
+		cn.lru.Remove(cf.elem)
+		cf.elem = nil
+	}
+	_ = os.Remove(cf.disk)
+	if cf.done.Load() {
+		cn.saveLocked()
+	} else {
+		cf.fail(errCacheAborted)
 	}
 }
 
 type cdnFile struct {
-	file *file
-	disk string
-	done atomic.Bool
-	tmp  *os.File
+	id     int64
+	name   string
+	size   int64
+	sha1   string
+	disk   string
+	done   atomic.Bool
+	tmp    *os.File
+	access time.Time     // 最近访问时间
+	elem   *list.Element // 在 LRU 中的位置，缓存完毕后才有
+
+	mu      sync.Mutex // 保护以下写入进度
+	cond    *sync.Cond // 写入进度变化通知
+	written int64      // 已写入磁盘的字节数
+	err     error      // 缓存失败的原因
+}
+
+// progress 增加写入进度并通知等待的读者，缓存已失败时返回错误。
+func (cf *cdnFile) progress(n int64) error {
+	cf.mu.Lock()
+	defer cf.mu.Unlock()
+
+	if cf.err != nil {
+		return cf.err
+	}
+	if n > 0 {
+		cf.written += n
+		cf.cond.Broadcast()
+	}
+	return nil
 }
 
-func (cf *cdnFile) Open() (File, error) {
+// fail 标记缓存失败，正在跟随读取的读者会切换到数据库读取。
+func (cf *cdnFile) fail(err error) {
+	if cf.cond == nil {
+		return
+	}
+	cf.mu.Lock()
+	if cf.err == nil {
+		cf.err = err
+	}
+	cf.mu.Unlock()
+	cf.cond.Broadcast()
+}
+
+// tail 打开正在缓存的文件，跟随写入进度读取
+func (cf *cdnFile) tail(mfl *file) (File, error) {
+	open, err := os.Open(cf.disk)
+	if err != nil {
+		return mfl, nil
+	}
+
+	return &tailFile{cf: cf, diskFile: open, rawFile: mfl}, nil
+}
+
+// Open 打开缓存文件，mfl 为数据库中最新的文件信息
+func (cf *cdnFile) Open(mfl *file) (File, error) {
 	open, err := os.Open(cf.disk)
 	if err != nil {
 		return nil, err
 	}
 
-	wf := &warpedFile{diskFile: open, rawFile: cf.file}
+	wf := &warpedFile{diskFile: open, rawFile: mfl}
 
 	return wf, nil
 }
@@ -173,41 +463,187 @@ func (wf *warpedFile) Seek(offset int64, whence int) (int64, error) {
 	return wf.diskFile.Seek(offset, whence)
 }
 
-type cdnCaching struct {
-	cdn     *cdn
-	cdnFile *cdnFile
-	rawFile *file
-	teeRead io.Reader
+// ReadAt 实现 io.ReaderAt
+func (wf *warpedFile) ReadAt(p []byte, off int64) (int, error) {
+	return wf.diskFile.ReadAt(p, off)
+}
+
+// tailFile 跟随 CDN 缓存写入进度读取的文件，缓存失败时从当前位置
+// 切换为数据库读取。
+type tailFile struct {
+	cf       *cdnFile
+	diskFile *os.File
+	rawFile  *file
+	offset   int64
+	fallback bool // 是否已经切换为数据库读取
+	closed   bool
+}
+
+func (tf *tailFile) Stat() (fs.FileInfo, error) { return tf.rawFile.Stat() }
+func (tf *tailFile) Name() string               { return tf.rawFile.Name() }
+func (tf *tailFile) Size() int64                { return tf.rawFile.Size() }
+func (tf *tailFile) Mode() fs.FileMode          { return tf.rawFile.Mode() }
+func (tf *tailFile) ModTime() time.Time         { return tf.rawFile.ModTime() }
+func (tf *tailFile) IsDir() bool                { return tf.rawFile.IsDir() }
+func (tf *tailFile) Sys() any                   { return tf.rawFile.Sys() }
+func (tf *tailFile) Checksum() string           { return tf.rawFile.Checksum() }
+func (tf *tailFile) ContentType() string        { return tf.rawFile.ContentType() }
+func (tf *tailFile) ContentLength() string      { return tf.rawFile.ContentLength() }
+func (tf *tailFile) Attachment() string         { return tf.rawFile.Attachment() }
+func (tf *tailFile) ID() int64                  { return tf.rawFile.ID() }
+
+func (tf *tailFile) Read(p []byte) (int, error) {
+	if len(p) == 0 {
+		return 0, nil
+	}
+	if tf.fallback {
+		n, err := tf.rawFile.Read(p)
+		tf.offset += int64(n)
+		return n, err
+	}
+
+	avail, err := tf.wait(tf.offset)
+	if err != nil {
+		if err == errCacheFallback {
+			return tf.Read(p)
+		}
+		return 0, err
+	}
+	if int64(len(p)) > avail {
+		p = p[:avail]
+	}
+	n, err := tf.diskFile.ReadAt(p, tf.offset)
+	tf.offset += int64(n)
+	if err == io.EOF && n > 0 {
+		err = nil
+	}
+
+	return n, err
 }
 
-func (cc *cdnCaching) Stat() (fs.FileInfo, error) { return cc.rawFile.Stat() }
-func (cc *cdnCaching) Name() string               { return cc.rawFile.Name() }
-func (cc *cdnCaching) Size() int64                { return cc.rawFile.Size() }
-func (cc *cdnCaching) Mode() fs.FileMode          { return cc.rawFile.Mode() }
-func (cc *cdnCaching) ModTime() time.Time         { return cc.rawFile.ModTime() }
-func (cc *cdnCaching) IsDir() bool                { return cc.rawFile.IsDir() }
-func (cc *cdnCaching) Sys() any                   { return cc.rawFile.Sys() }
-func (cc *cdnCaching) Checksum() string           { return cc.rawFile.Checksum() }
-func (cc *cdnCaching) ContentType() string        { return cc.rawFile.ContentType() }
-func (cc *cdnCaching) ContentLength() string      { return cc.rawFile.ContentLength() }
-func (cc *cdnCaching) Attachment() string         { return cc.rawFile.Attachment() }
-func (cc *cdnCaching) ID() int64                  { return cc.rawFile.ID() }
+// Seek 实现 io.Seeker，定位到尚未写入的位置时，Read 会等待写入进度。
+func (tf *tailFile) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += tf.offset
+	case io.SeekEnd:
+		offset += tf.rawFile.filesize
+	default:
+		return 0, fs.ErrInvalid
+	}
+	if offset < 0 {
+		return 0, fs.ErrInvalid
+	}
+	if tf.fallback {
+		if _, err := tf.rawFile.Seek(offset, io.SeekStart); err != nil {
+			return 0, err
+		}
+	}
+	tf.offset = offset
+
+	return offset, nil
+}
 
-func (cc *cdnCaching) Read(p []byte) (n int, err error) {
-	if n, err = cc.teeRead.Read(p); err == io.EOF {
-		// 如果 io.EOF 代表文件已经读取完毕，将 CDN 缓存任务状态设置为 done
-		cc.cdnFile.done.Store(true)
-		_ = cc.cdnFile.tmp.Close()
+// ReadAt 实现 io.ReaderAt
+func (tf *tailFile) ReadAt(p []byte, off int64) (int, error) {
+	var n int
+	for n < len(p) {
+		if tf.fallback {
+			i, err := tf.rawFile.ReadAt(p[n:], off+int64(n))
+			return n + i, err
+		}
+		avail, err := tf.wait(off + int64(n))
+		if err == errCacheFallback {
+			continue
+		}
+		if err != nil {
+			return n, err
+		}
+		buf := p[n:]
+		if int64(len(buf)) > avail {
+			buf = buf[:avail]
+		}
+		i, err := tf.diskFile.ReadAt(buf, off+int64(n))
+		n += i
+		if err != nil && err != io.EOF {
+			return n, err
+		}
 	}
 
-	return
+	return n, nil
+}
+
+func (tf *tailFile) Close() error {
+	cf := tf.cf
+	cf.mu.Lock()
+	tf.closed = true
+	cf.mu.Unlock()
+	cf.cond.Broadcast()
+
+	_ = tf.rawFile.Close()
+	return tf.diskFile.Close()
+}
+
+// wait 等待 off 位置之后有数据可读，返回可读的字节数。
+// 缓存失败时切换为数据库读取并返回 errCacheFallback，
+// 打开文件时的 ctx 取消后返回 ctx.Err()。
+func (tf *tailFile) wait(off int64) (int64, error) {
+	ctx := tf.rawFile.context()
+	cf := tf.cf
+	cf.mu.Lock()
+	defer cf.mu.Unlock()
+
+	var watching bool
+	for {
+		if tf.closed {
+			return 0, fs.ErrClosed
+		}
+		if err := ctx.Err(); err != nil {
+			return 0, err
+		}
+		if off >= cf.size {
+			return 0, io.EOF
+		}
+		if avail := cf.written - off; avail > 0 {
+			return avail, nil
+		}
+		if cf.err != nil {
+			if _, err := tf.rawFile.Seek(tf.offset, io.SeekStart); err != nil {
+				return 0, err
+			}
+			tf.fallback = true
+			return 0, errCacheFallback
+		}
+		if !watching {
+			watching = true
+			stop := tf.watch(ctx)
+			defer close(stop)
+		}
+		cf.cond.Wait()
+	}
 }
 
-func (cc *cdnCaching) Close() error {
-	if !cc.cdnFile.done.Load() {
-		_ = cc.cdnFile.tmp.Close()
-		cc.cdn.removeID(cc.rawFile.id)
+// watch ctx 取消时唤醒 wait，关闭返回的 chan 后停止监听。
+// 调用时须持有 cf.mu。
+func (tf *tailFile) watch(ctx context.Context) chan struct{} {
+	stop := make(chan struct{})
+	done := ctx.Done()
+	if done == nil {
+		return stop
 	}
 
-	return cc.rawFile.Close()
+	cf := tf.cf
+	go func() {
+		select {
+		case <-done:
+			// 加锁保证 wait 已经进入 cond.Wait，不会错过通知
+			cf.mu.Lock()
+			cf.mu.Unlock()
+			cf.cond.Broadcast()
+		case <-stop:
+		}
+	}()
+
+	return stop
 }
@@ -0,0 +1,144 @@
+package grid
+
+import (
+	"database/sql"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// cdnCacheDir 缓存专用的子目录，启动时只清理该目录
+	cdnCacheDir = "grid-cdn"
+
+	// cdnIndexName CDN 缓存索引文件名
+	cdnIndexName = "index.json"
+)
+
+// cdnIndexEntry 索引中的一条缓存记录
+type cdnIndexEntry struct {
+	ID     int64     `json:"id,string"`
+	Name   string    `json:"name"`
+	Size   int64     `json:"size"`
+	SHA1   string    `json:"sha1"`
+	Disk   string    `json:"disk"` // 缓存文件名（不含目录）
+	Access time.Time `json:"access"`
+}
+
+// reload 启动时加载索引：与 grid_file.sha1 比对校验，清理失效的缓存，
+// 同时删除缓存目录下不在索引中的 {id}-{name} 缓存文件（如：上次未缓存完
+// 就退出了）。cn.dir 是缓存专用的子目录，不会误删调用方的文件。
+func (cn *cdn) reload() {
+	cn.mutex.Lock()
+	defer cn.mutex.Unlock()
+
+	var entries []*cdnIndexEntry
+	if raw, err := os.ReadFile(filepath.Join(cn.dir, cdnIndexName)); err == nil {
+		_ = json.Unmarshal(raw, &entries)
+	}
+	// 按照访问时间从旧到新加入 LRU，最近访问的在最前面
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Access.Before(entries[j].Access) })
+
+	keep := make(map[string]struct{}, len(entries))
+	for _, ent := range entries {
+		disk := filepath.Join(cn.dir, filepath.Base(ent.Disk))
+		if !cn.validEntry(ent, disk) {
+			continue
+		}
+		cf := &cdnFile{
+			id:     ent.ID,
+			name:   ent.Name,
+			size:   ent.Size,
+			sha1:   ent.SHA1,
+			disk:   disk,
+			access: ent.Access,
+		}
+		cf.done.Store(true)
+		cf.elem = cn.lru.PushFront(cf)
+		cn.files[cf.id] = cf
+		cn.used += cf.size
+		keep[filepath.Base(disk)] = struct{}{}
+	}
+
+	// 清理不在索引中的缓存文件
+	if dirs, err := os.ReadDir(cn.dir); err == nil {
+		for _, d := range dirs {
+			name := d.Name()
+			if _, ok := keep[name]; ok || d.IsDir() || !isCacheName(name) {
+				continue
+			}
+			_ = os.Remove(filepath.Join(cn.dir, name))
+		}
+	}
+
+	// 超出配额的部分按照 LRU 淘汰
+	for cn.quota > 0 && cn.used > cn.quota {
+		back := cn.lru.Back()
+		if back == nil {
+			break
+		}
+		cn.evictLocked(back.Value.(*cdnFile))
+	}
+	cn.saveLocked()
+}
+
+// validEntry 校验缓存文件是否存在、大小是否正确、是否与数据库一致
+func (cn *cdn) validEntry(ent *cdnIndexEntry, disk string) bool {
+	stat, err := os.Stat(disk)
+	if err != nil || stat.Size() != ent.Size {
+		return false
+	}
+	if cn.db == nil {
+		return true
+	}
+
+	var sum string
+	var done bool
+	rawSQL := "SELECT sha1, done FROM grid_file WHERE id = ?"
+	if err = cn.db.QueryRow(rawSQL, ent.ID).Scan(&sum, &done); err != nil {
+		// 数据库暂时不可用时先保留，打开文件时还会再次比对 sha1
+		return err != sql.ErrNoRows
+	}
+
+	return done && sum == ent.SHA1
+}
+
+// saveLocked 将已缓存完毕的文件写入索引，先写临时文件再重命名保证原子性。
+func (cn *cdn) saveLocked() {
+	entries := make([]*cdnIndexEntry, 0, cn.lru.Len())
+	for elem := cn.lru.Front(); elem != nil; elem = elem.Next() {
+		cf := elem.Value.(*cdnFile)
+		entries = append(entries, &cdnIndexEntry{
+			ID:     cf.id,
+			Name:   cf.name,
+			Size:   cf.size,
+			SHA1:   cf.sha1,
+			Disk:   filepath.Base(cf.disk),
+			Access: cf.access,
+		})
+	}
+	raw, err := json.Marshal(entries)
+	if err != nil {
+		return
+	}
+
+	dest := filepath.Join(cn.dir, cdnIndexName)
+	tmp := dest + ".tmp"
+	if err = os.WriteFile(tmp, raw, 0o644); err == nil {
+		_ = os.Rename(tmp, dest)
+	}
+}
+
+// isCacheName 是否是 {id}-{name} 格式的缓存文件名
+func isCacheName(name string) bool {
+	id, _, found := strings.Cut(name, "-")
+	if !found || id == "" {
+		return false
+	}
+	_, err := strconv.ParseInt(id, 10, 64)
+	return err == nil
+}
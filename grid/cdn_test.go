@@ -0,0 +1,68 @@
+package grid
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestTailFileCancel(t *testing.T) {
+	disk := filepath.Join(t.TempDir(), "1-tail")
+	if err := os.WriteFile(disk, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	cf := &cdnFile{id: 1, size: 100, disk: disk}
+	cf.cond = sync.NewCond(&cf.mu)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	tf, err := cf.tail(&file{id: 1, filesize: 100, ctx: ctx})
+	if err != nil {
+		t.Fatal(err)
+	}
+	//goland:noinspection GoUnhandledErrorResult
+	defer tf.Close()
+
+	errC := make(chan error, 1)
+	go func() {
+		_, err := tf.Read(make([]byte, 10))
+		errC <- err
+	}()
+	time.Sleep(20 * time.Millisecond)
+	cancel()
+
+	select {
+	case err = <-errC:
+		if err != context.Canceled {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("read still blocked after cancel")
+	}
+}
+
+func TestCDNReloadKeepsCallerFiles(t *testing.T) {
+	dir := t.TempDir()
+	cache := filepath.Join(dir, cdnCacheDir)
+	if err := os.MkdirAll(cache, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	report := filepath.Join(dir, "2024-report.pdf")
+	stale := filepath.Join(cache, "5-stale.bin")
+	for _, name := range []string{report, stale} {
+		if err := os.WriteFile(name, []byte("x"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	NewCDN(nil, dir, 0)
+
+	if _, err := os.Stat(report); err != nil {
+		t.Fatalf("caller file removed: %v", err)
+	}
+	if _, err := os.Stat(stale); !os.IsNotExist(err) {
+		t.Fatalf("stale cache file kept: %v", err)
+	}
+}
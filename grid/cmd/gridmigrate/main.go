@@ -0,0 +1,75 @@
+// gridmigrate 将 grid 文件分片在不同的存储后端之间迁移，文件 ID 与校验码保持不变。
+//
+//	gridmigrate -dsn 'user:pass@tcp(127.0.0.1:3306)/ssoc?parseTime=true' \
+//		-from mysql -to 'dir:///var/lib/grid'
+//
+// 存储地址格式见 grid.OpenStore，迁移期间应停止文件上传。
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"flag"
+	"log"
+	"os"
+	"os/signal"
+
+	_ "github.com/go-sql-driver/mysql"
+	"github.com/vela-ssoc/backend-common/grid"
+)
+
+func main() {
+	dsn := flag.String("dsn", "", "MySQL DSN（需要 parseTime=true）")
+	from := flag.String("from", "mysql", "源存储地址")
+	to := flag.String("to", "", "目标存储地址")
+	del := flag.Bool("delete", false, "迁移并校验成功后删除源存储中的分片")
+	flag.Parse()
+
+	if *dsn == "" || *to == "" || *from == *to {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	db, err := sql.Open("mysql", *dsn)
+	if err != nil {
+		log.Fatalf("连接数据库失败：%v", err)
+	}
+	//goland:noinspection GoUnhandledErrorResult
+	defer db.Close()
+
+	src, err := grid.OpenStore(db, *from)
+	if err != nil {
+		log.Fatalf("源存储地址 %s 错误：%v", *from, err)
+	}
+	dst, err := grid.OpenStore(db, *to)
+	if err != nil {
+		log.Fatalf("目标存储地址 %s 错误：%v", *to, err)
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer cancel()
+
+	opt := grid.MigrateOptions{
+		DeleteSource: *del,
+		OnFile: func(id, parts int64, err error) {
+			if err != nil {
+				log.Printf("文件 %d 迁移失败：%v", id, err)
+			} else {
+				log.Printf("文件 %d 迁移完毕，共 %d 个分片", id, parts)
+			}
+		},
+	}
+	report, err := grid.Migrate(ctx, db, src, dst, opt)
+	if report != nil {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		_ = enc.Encode(report)
+	}
+	if err != nil {
+		log.Fatalf("迁移中断：%v", err)
+	}
+	if len(report.Failed) != 0 {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,197 @@
+package grid
+
+import (
+	"bytes"
+	"compress/flate"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"io"
+	"sync"
+)
+
+// CodecFlate 分片使用 DEFLATE 压缩，grid_file.codec 为空代表不压缩。
+const CodecFlate = "flate"
+
+var (
+	// ErrCodec 不支持的分片编码
+	ErrCodec = errors.New("grid: unsupported part codec")
+
+	// ErrKeyNotFound 找不到 grid_file.key_id 对应的密钥
+	ErrKeyNotFound = errors.New("grid: encryption key not found")
+
+	// ErrPartCorrupted 分片解密或解压失败
+	ErrPartCorrupted = errors.New("grid: part corrupted")
+)
+
+// Keyring 分片加密密钥，grid_file.key_id 记录了加密时使用的密钥 ID，轮换
+// 密钥时新增 ID 即可，旧的密钥要保留到使用它的文件全部删除为止。
+type Keyring interface {
+	// Key 返回 ID 对应的 AES 密钥（16/24/32 字节），不存在时返回 ErrKeyNotFound。
+	Key(id string) ([]byte, error)
+}
+
+// StaticKeyring 固定的密钥表
+type StaticKeyring map[string][]byte
+
+func (sk StaticKeyring) Key(id string) ([]byte, error) {
+	if key, ok := sk[id]; ok {
+		return key, nil
+	}
+	return nil, ErrKeyNotFound
+}
+
+// WriteOptions 文件写入参数
+type WriteOptions struct {
+	Codec string // 压缩方式，目前只支持 CodecFlate，为空代表不压缩
+	KeyID string // 加密密钥 ID，为空代表不加密
+}
+
+// partCodec 分片编解码：先压缩再使用 AES-GCM 加密，grid_file.size 与 sha1
+// 始终是明文的大小和校验码，对 File.Read 透明。
+//
+// 压缩后的分片第一个字节为标记位：0 原样保存（压缩后反而变大）、1 DEFLATE；
+// 加密后的分片为 12 字节随机 nonce + 密文，分片所属 ID 和序号作为附加数据，
+// 防止分片被调换位置。
+type partCodec struct {
+	compress bool
+	aead     cipher.AEAD
+	err      error // 密钥不可用，读写时返回该错误
+}
+
+func newPartCodec(codec, keyID string, keys Keyring) (*partCodec, error) {
+	if codec == "" && keyID == "" {
+		return nil, nil
+	}
+	pc := new(partCodec)
+	switch codec {
+	case "":
+	case CodecFlate:
+		pc.compress = true
+	default:
+		return nil, ErrCodec
+	}
+	if keyID == "" {
+		return pc, nil
+	}
+
+	if keys == nil {
+		return nil, ErrKeyNotFound
+	}
+	key, err := keys.Key(keyID)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	if pc.aead, err = cipher.NewGCM(block); err != nil {
+		return nil, err
+	}
+
+	return pc, nil
+}
+
+var flateWriters = sync.Pool{
+	New: func() any {
+		w, _ := flate.NewWriter(nil, flate.DefaultCompression)
+		return w
+	},
+}
+
+func (pc *partCodec) encode(id, serial int64, data []byte) ([]byte, error) {
+	if pc == nil {
+		return data, nil
+	}
+	if pc.err != nil {
+		return nil, pc.err
+	}
+
+	if pc.compress {
+		buf := bytes.NewBuffer(make([]byte, 0, len(data)/2+1))
+		buf.WriteByte(1)
+		fw := flateWriters.Get().(*flate.Writer)
+		fw.Reset(buf)
+		_, err := fw.Write(data)
+		if err == nil {
+			err = fw.Close()
+		}
+		flateWriters.Put(fw)
+		if err != nil {
+			return nil, err
+		}
+		if buf.Len() <= len(data) {
+			data = buf.Bytes()
+		} else {
+			raw := make([]byte, len(data)+1)
+			copy(raw[1:], data)
+			data = raw
+		}
+	}
+
+	if pc.aead != nil {
+		size := pc.aead.NonceSize()
+		dst := make([]byte, size, size+len(data)+pc.aead.Overhead())
+		if _, err := io.ReadFull(rand.Reader, dst); err != nil {
+			return nil, err
+		}
+		data = pc.aead.Seal(dst, dst, data, partAD(id, serial))
+	}
+
+	return data, nil
+}
+
+func (pc *partCodec) decode(id, serial int64, data []byte) ([]byte, error) {
+	if pc == nil {
+		return data, nil
+	}
+	if pc.err != nil {
+		return nil, pc.err
+	}
+
+	if pc.aead != nil {
+		size := pc.aead.NonceSize()
+		if len(data) < size {
+			return nil, ErrPartCorrupted
+		}
+		plain, err := pc.aead.Open(nil, data[:size], data[size:], partAD(id, serial))
+		if err != nil {
+			return nil, ErrPartCorrupted
+		}
+		data = plain
+	}
+
+	if pc.compress {
+		if len(data) == 0 {
+			return nil, ErrPartCorrupted
+		}
+		flag, body := data[0], data[1:]
+		switch flag {
+		case 0:
+			data = body
+		case 1:
+			fr := flate.NewReader(bytes.NewReader(body))
+			plain, err := io.ReadAll(fr)
+			_ = fr.Close()
+			if err != nil {
+				return nil, ErrPartCorrupted
+			}
+			data = plain
+		default:
+			return nil, ErrPartCorrupted
+		}
+	}
+
+	return data, nil
+}
+
+// partAD 加密附加数据：分片所属 ID + 分片序号
+func partAD(id, serial int64) []byte {
+	ad := make([]byte, 16)
+	binary.BigEndian.PutUint64(ad, uint64(id))
+	binary.BigEndian.PutUint64(ad[8:], uint64(serial))
+	return ad
+}
@@ -0,0 +1,74 @@
+package grid
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"testing"
+)
+
+func TestPartCodec(t *testing.T) {
+	keys := StaticKeyring{"k1": bytes.Repeat([]byte{7}, 32)}
+	plain := bytes.Repeat([]byte("grid codec "), 100)
+	random := make([]byte, 256)
+	for i := range random {
+		random[i] = byte(i * 131)
+	}
+
+	for _, opt := range []WriteOptions{{Codec: CodecFlate}, {KeyID: "k1"}, {Codec: CodecFlate, KeyID: "k1"}} {
+		pc, err := newPartCodec(opt.Codec, opt.KeyID, keys)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, data := range [][]byte{plain, random, {}} {
+			enc, err := pc.encode(3, 1, data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			dec, err := pc.decode(3, 1, enc)
+			if err != nil || !bytes.Equal(dec, data) {
+				t.Fatalf("%+v: roundtrip failed: %v", opt, err)
+			}
+			if opt.KeyID != "" {
+				if _, err = pc.decode(3, 2, enc); err != ErrPartCorrupted {
+					t.Fatalf("%+v: swapped part should be rejected: %v", opt, err)
+				}
+			}
+		}
+	}
+
+	if _, err := newPartCodec("", "k2", keys); err != ErrKeyNotFound {
+		t.Fatalf("unknown key: %v", err)
+	}
+}
+
+func TestFileCodec(t *testing.T) {
+	st, err := NewDirStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	pc, _ := newPartCodec(CodecFlate, "k1", StaticKeyring{"k1": bytes.Repeat([]byte{1}, 16)})
+
+	content := bytes.Repeat([]byte("0123456789abcdef"), 40)
+	burst := 64
+	for i := 0; i*burst < len(content); i++ {
+		end := (i + 1) * burst
+		if end > len(content) {
+			end = len(content)
+		}
+		data, _ := pc.encode(9, int64(i), content[i*burst:end])
+		if err = st.WritePart(context.Background(), 9, int64(i), data); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	fl := &file{id: 9, filesize: int64(len(content)), burst: burst, fixed: true, store: st, pc: pc}
+	got, err := io.ReadAll(fl)
+	if err != nil || !bytes.Equal(got, content) {
+		t.Fatalf("read: %v", err)
+	}
+	p := make([]byte, 100)
+	if n, err := fl.ReadAt(p, 60); err != nil || !bytes.Equal(p[:n], content[60:160]) {
+		t.Fatalf("read at: %d %v", n, err)
+	}
+}
@@ -0,0 +1,172 @@
+package grid
+
+import (
+	"context"
+	"database/sql"
+	"io/fs"
+	"strings"
+	"time"
+)
+
+// blob 去重后的文件内容（MySQL），同一份内容（sha1、size、burst 以及分片的
+// 编码方式均相同）
+// 文件共享同一组分片，refs 为引用该内容的文件数，最后一个引用删除时才会
+// 删除分片。blob 的 ID 就是第一次上传该内容的文件 ID，也就是 grid_part.file_id。
+// CREATE TABLE `grid_blob`
+// (
+//
+//	`id`         BIGINT                               NOT NULL COMMENT '内容 ID（即 grid_part.file_id）',
+//	`sha1`       CHAR(40)                             NOT NULL COMMENT '内容 SHA1',
+//	`size`       BIGINT     DEFAULT 0                 NOT NULL COMMENT '内容大小',
+//	`burst`      INT        DEFAULT 0                 NOT NULL COMMENT '分片大小',
+//	`codec`      VARCHAR(16) DEFAULT ''               NOT NULL COMMENT '分片压缩方式',
+//	`key_id`     VARCHAR(64) DEFAULT ''               NOT NULL COMMENT '分片加密密钥 ID',
+//	`fixed`      TINYINT(1) DEFAULT 0                 NOT NULL COMMENT '除最后一个分片外是否都是 burst 大小',
+//	`refs`       BIGINT     DEFAULT 0                 NOT NULL COMMENT '引用计数',
+//	`created_at` DATETIME   DEFAULT CURRENT_TIMESTAMP NOT NULL COMMENT '创建时间',
+//	CONSTRAINT grid_blob_pk PRIMARY KEY (`id`),
+//	CONSTRAINT grid_blob_pk2 UNIQUE (`sha1`, `size`, `burst`, `codec`, `key_id`)
+//
+// ) COMMENT '文件内容';
+
+// Deduper 按内容去重：相同内容的文件只保存一份分片。
+type Deduper interface {
+	// StatChecksum 通过 SHA-1 查询已上传完毕的文件，不存在时返回
+	// fs.ErrNotExist，存在时可以通过 Link 直接创建文件而无需再次上传。
+	StatChecksum(sum string) (File, error)
+
+	// Link 引用已存在的内容创建一个新文件，不存在时返回 fs.ErrNotExist。
+	Link(sum, name string) (File, error)
+}
+
+func (gfs *gridFS) StatChecksum(sum string) (File, error) {
+	sum = strings.ToLower(sum)
+	rawSQL := "SELECT " + fileColumns + " FROM grid_file " +
+		"WHERE sha1 = ? AND done = ? AND blob_id <> 0 ORDER BY id DESC LIMIT 1"
+	fl, err := gfs.scanFile(gfs.db.QueryRow(rawSQL, sum, true))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fs.ErrNotExist
+		}
+		return nil, err
+	}
+
+	return fl, nil
+}
+
+func (gfs *gridFS) Link(sum, name string) (File, error) {
+	sum = strings.ToLower(sum)
+	tx, err := gfs.db.BeginTx(context.Background(), &sql.TxOptions{})
+	if err != nil {
+		return nil, err
+	}
+	//goland:noinspection GoUnhandledErrorResult
+	defer tx.Rollback()
+
+	// 优先引用与默认写入参数编码方式相同的内容
+	var blobID, size int64
+	var burst int
+	var codec, keyID string
+	var fixed bool
+	querySQL := "SELECT id, size, burst, codec, key_id, fixed FROM grid_blob WHERE sha1 = ? " +
+		"ORDER BY codec = ? AND key_id = ? DESC, id LIMIT 1 FOR UPDATE"
+	if err = tx.QueryRow(querySQL, sum, gfs.wopt.Codec, gfs.wopt.KeyID).
+		Scan(&blobID, &size, &burst, &codec, &keyID, &fixed); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fs.ErrNotExist
+		}
+		return nil, err
+	}
+
+	updateBlob := "UPDATE grid_blob SET refs = refs + 1 WHERE id = ?"
+	if _, err = tx.Exec(updateBlob, blobID); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	insertFile := "INSERT INTO grid_file(`name`, size, sha1, burst, done, blob_id, codec, key_id, fixed, created_at, updated_at) " +
+		"VALUE (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)"
+	ret, err := tx.Exec(insertFile, name, size, sum, burst, true, blobID, codec, keyID, fixed, now, now)
+	if err != nil {
+		return nil, err
+	}
+	fileID, err := ret.LastInsertId()
+	if err != nil {
+		return nil, err
+	}
+	if err = tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	fl := &file{
+		id:        fileID,
+		filename:  name,
+		filesize:  size,
+		sha1:      sum,
+		burst:     burst,
+		done:      true,
+		blobID:    blobID,
+		codec:     codec,
+		keyID:     keyID,
+		fixed:     fixed,
+		createdAt: now,
+		updatedAt: now,
+		store:     gfs.store,
+		pc:        gfs.partCodec(codec, keyID),
+		prefetch:  gfs.prefetch,
+	}
+
+	return fl, nil
+}
+
+// blobRef 文件分片实际所属的内容
+type blobRef struct {
+	id        int64     // 内容 ID
+	fixed     bool      // 分片大小是否固定，复用旧版本写入的内容时为 false
+	updatedAt time.Time // 文件置为上传完毕的时间
+}
+
+// shareBlob 文件分片写入完毕后登记内容，返回分片实际所属的内容，其 ID
+// 与 fileID 不同时代表已经存在相同的内容，提交后要删除 fileID 的分片。
+// 新写入的分片大小总是固定的。
+func shareBlob(tx *sql.Tx, fileID, size int64, sum string, burst int, opt WriteOptions) (*blobRef, error) {
+	insertBlob := "INSERT INTO grid_blob (id, sha1, size, burst, codec, key_id, fixed, refs, created_at) " +
+		"VALUE (?, ?, ?, ?, ?, ?, ?, ?, ?) ON DUPLICATE KEY UPDATE refs = refs + 1"
+	if _, err := tx.Exec(insertBlob, fileID, sum, size, burst, opt.Codec, opt.KeyID, true, 1, time.Now()); err != nil {
+		return nil, err
+	}
+
+	blob := new(blobRef)
+	querySQL := "SELECT id, fixed FROM grid_blob WHERE sha1 = ? AND size = ? AND burst = ? AND codec = ? AND key_id = ?"
+	if err := tx.QueryRow(querySQL, sum, size, burst, opt.Codec, opt.KeyID).Scan(&blob.id, &blob.fixed); err != nil {
+		return nil, err
+	}
+
+	return blob, nil
+}
+
+// releaseBlob 减少内容的引用计数，返回值代表提交后是否要删除分片：最后一个
+// 引用删除时，或者没有登记过的内容（去重之前上传的文件或未提交的上传会话）。
+func releaseBlob(tx *sql.Tx, blobID int64) (bool, error) {
+	updateBlob := "UPDATE grid_blob SET refs = refs - 1 WHERE id = ?"
+	ret, err := tx.Exec(updateBlob, blobID)
+	if err != nil {
+		return false, err
+	}
+	if n, _ := ret.RowsAffected(); n == 0 {
+		return true, nil
+	}
+
+	var refs int64
+	querySQL := "SELECT refs FROM grid_blob WHERE id = ?"
+	if err = tx.QueryRow(querySQL, blobID).Scan(&refs); err != nil {
+		return false, err
+	}
+	if refs > 0 {
+		return false, nil
+	}
+	deleteBlob := "DELETE FROM grid_blob WHERE id = ?"
+	_, err = tx.Exec(deleteBlob, blobID)
+
+	return err == nil, err
+}
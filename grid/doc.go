@@ -13,4 +13,7 @@
 // 综合考虑：我们项目中的文件不会很多，文件一般也不大，基本都在几兆到几
 // 十兆，为了减少数据库的 IO，还增加了 CDN 功能（即：grid.NewCDN），每
 // 个节点都会将文件缓存到本地磁盘，以后的下载就不走数据库了
+//
+// 文件较多的部署可以通过 WithStore 将分片放到本地目录或 S3 兼容的对象
+// 存储中，数据库只保存文件信息，已有的分片可以使用 cmd/gridmigrate 迁移。
 package grid
@@ -1,7 +1,8 @@
 package grid
 
 import (
-	"database/sql"
+	"context"
+	"errors"
 	"io"
 	"io/fs"
 	"mime"
@@ -11,6 +12,9 @@ import (
 	"time"
 )
 
+// errPartGap 批量读取时遇到了不连续的分片
+var errPartGap = errors.New("grid: part gap")
+
 type File interface {
 	fs.File
 	fs.FileInfo
@@ -31,9 +35,14 @@ type File interface {
 //	`sha1`       CHAR(40)                             NOT NULL COMMENT '文件 SHA1',
 //	`burst`      INT        DEFAULT 0                 NOT NULL COMMENT '分片大小（单位：bytes，要和 grid_part.data 配合使用）',
 //	`done`       TINYINT(1) DEFAULT 0                 NOT NULL COMMENT '是否上传完毕',
+//	`blob_id`    BIGINT     DEFAULT 0                 NOT NULL COMMENT '分片所属的内容 ID（grid_blob.id），0 代表分片属于自身',
+//	`codec`      VARCHAR(16) DEFAULT ''               NOT NULL COMMENT '分片压缩方式，空代表不压缩',
+//	`key_id`     VARCHAR(64) DEFAULT ''               NOT NULL COMMENT '分片加密密钥 ID，空代表不加密',
+//	`fixed`      TINYINT(1) DEFAULT 0                 NOT NULL COMMENT '除最后一个分片外是否都是 burst 大小，0 代表分片大小不固定',
 //	`created_at` DATETIME   DEFAULT CURRENT_TIMESTAMP NOT NULL COMMENT '创建时间（一般代表上传开始时间）',
 //	`updated_at` DATETIME   DEFAULT CURRENT_TIMESTAMP NOT NULL COMMENT '更新时间（一般代表上传结束时间）',
-//	CONSTRAINT grid_file_pk PRIMARY KEY (`id`)
+//	CONSTRAINT grid_file_pk PRIMARY KEY (`id`),
+//	INDEX grid_file_sha1_index (`sha1`)
 //
 // ) COMMENT '文件信息表';
 type file struct {
@@ -43,13 +52,24 @@ type file struct {
 	sha1      string
 	burst     int
 	done      bool
+	blobID    int64  // 分片所属的内容 ID，0 代表分片属于自身
+	codec     string // 分片压缩方式
+	keyID     string // 分片加密密钥 ID
+	fixed     bool   // 除最后一个分片外都是 burst 大小，旧版本写入的文件分片大小不固定
 	createdAt time.Time
 	updatedAt time.Time
 
-	db     *sql.DB // 数据库连接
-	serial int64   // 分片序号
-	buffer []byte  // 缓存
-	eof    bool    // 是否读完了
+	store    Store           // 分片存储
+	pc       *partCodec      // 分片编解码，为空代表原样保存
+	ctx      context.Context // 读取分片使用的 ctx，为空代表 context.Background()
+	prefetch int             // 每次查询读取的分片数
+	serial   int64           // 下一个要查询的分片序号
+	pending  [][]byte        // 预读的分片
+	buffer   []byte          // 缓存
+	eof      bool            // 是否读完了
+	err      error           // 读取分片出错的原因，之后的 Read 都返回该错误
+	offset   int64           // 当前读取位置
+	skip     int64           // Seek 后需要跳过的字节数
 }
 
 func (fl *file) ID() int64 {
@@ -82,6 +102,27 @@ func (fl *file) Attachment() string {
 	return mime.FormatMediaType("attachment", pam)
 }
 
+// clone 复制文件信息，读取状态从头开始，不继承 ctx。
+func (fl *file) clone() *file {
+	return &file{
+		id:        fl.id,
+		filename:  fl.filename,
+		filesize:  fl.filesize,
+		sha1:      fl.sha1,
+		burst:     fl.burst,
+		done:      fl.done,
+		blobID:    fl.blobID,
+		codec:     fl.codec,
+		keyID:     fl.keyID,
+		fixed:     fl.fixed,
+		createdAt: fl.createdAt,
+		updatedAt: fl.updatedAt,
+		store:     fl.store,
+		pc:        fl.pc,
+		prefetch:  fl.prefetch,
+	}
+}
+
 func (fl *file) Close() error               { return nil }
 func (fl *file) Name() string               { return fl.filename }
 func (fl *file) Size() int64                { return fl.filesize }
@@ -92,18 +133,22 @@ func (fl *file) Sys() any                   { return nil }
 func (fl *file) Stat() (fs.FileInfo, error) { return fl, nil }
 
 func (fl *file) Read(p []byte) (int, error) {
-	if fl.db == nil {
+	if fl.store == nil {
 		return 0, fs.ErrInvalid
 	}
+	if fl.err != nil {
+		return 0, fl.err
+	}
 	if fl.eof {
 		return 0, io.EOF
 	}
 
 	var n int
+	var err error
 	psz := len(p)
 	for !fl.eof && psz > n {
 		if len(fl.buffer) == 0 {
-			if err := fl.readPart(); err != nil {
+			if err = fl.readPart(); err != nil {
 				break
 			}
 		}
@@ -112,25 +157,226 @@ func (fl *file) Read(p []byte) (int, error) {
 		fl.buffer = fl.buffer[i:]
 		n += i
 	}
+	fl.offset += int64(n)
 	if n > 0 {
 		return n, nil
 	}
+	if err != nil && err != io.EOF {
+		return 0, err
+	}
 
 	return n, io.EOF
 }
 
-// readPart 读取数据分片
+// Seek 实现 io.Seeker，根据偏移量计算分片序号，下次 Read 时从该分片开始读取。
+// 分片大小不固定的文件无法计算分片序号，下次 Read 时从头读取并跳过 offset 字节。
+func (fl *file) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += fl.offset
+	case io.SeekEnd:
+		offset += fl.filesize
+	default:
+		return 0, fs.ErrInvalid
+	}
+	if offset < 0 {
+		return 0, fs.ErrInvalid
+	}
+	if offset == fl.offset {
+		return offset, nil
+	}
+
+	fl.offset = offset
+	fl.buffer = nil
+	fl.pending = nil
+	fl.err = nil
+	fl.eof = offset >= fl.filesize
+	if fl.fixed && fl.burst > 0 {
+		burst := int64(fl.burst)
+		fl.serial = offset / burst
+		fl.skip = offset % burst
+	} else {
+		fl.serial, fl.skip = 0, offset
+	}
+
+	return offset, nil
+}
+
+// ReadAt 实现 io.ReaderAt，不影响 Read 的读取位置，一次查询读取所需的全部分片。
+// 分片大小不固定的文件从头顺序读取。
+func (fl *file) ReadAt(p []byte, off int64) (int, error) {
+	if fl.store == nil || off < 0 {
+		return 0, fs.ErrInvalid
+	}
+	if off >= fl.filesize {
+		return 0, io.EOF
+	}
+	if !fl.fixed || fl.burst <= 0 {
+		return fl.readAtLinear(p, off)
+	}
+
+	end := off + int64(len(p))
+	if end > fl.filesize {
+		end = fl.filesize
+	}
+	burst := int64(fl.burst)
+	first, last := off/burst, (end-1)/burst
+
+	var n int
+	expect := first
+	pid := fl.partID()
+	err := fl.store.ReadParts(fl.context(), pid, first, last, func(serial int64, data []byte) error {
+		if serial != expect { // 分片缺失
+			return io.ErrUnexpectedEOF
+		}
+		expect++
+		data, err := fl.pc.decode(pid, serial, data)
+		if err != nil {
+			return err
+		}
+
+		start := serial * burst
+		if skip := off + int64(n) - start; skip > 0 {
+			if skip >= int64(len(data)) {
+				return io.ErrUnexpectedEOF
+			}
+			data = data[skip:]
+		}
+		n += copy(p[n:], data)
+		return nil
+	})
+	if err != nil {
+		return n, err
+	}
+	if n < len(p) {
+		if off+int64(n) >= fl.filesize {
+			return n, io.EOF
+		}
+		return n, io.ErrUnexpectedEOF
+	}
+
+	return n, nil
+}
+
+// readAtLinear 从头顺序读取到 off 处，用于分片大小不固定的文件。
+func (fl *file) readAtLinear(p []byte, off int64) (int, error) {
+	rd := fl.clone()
+	rd.ctx = fl.ctx
+	if _, err := rd.Seek(off, io.SeekStart); err != nil {
+		return 0, err
+	}
+	n, err := io.ReadFull(rd, p)
+	if err == io.ErrUnexpectedEOF && off+int64(n) >= fl.filesize {
+		err = io.EOF
+	}
+
+	return n, err
+}
+
+// partID 分片所属的 ID（即 grid_part.file_id），去重后可能是其它文件的 ID。
+func (fl *file) partID() int64 {
+	if fl.blobID != 0 {
+		return fl.blobID
+	}
+	return fl.id
+}
+
+// context 读取分片使用的 ctx
+func (fl *file) context() context.Context {
+	if fl.ctx != nil {
+		return fl.ctx
+	}
+	return context.Background()
+}
+
+// readPart 读取数据分片，预读的分片用完后再批量查询。
 func (fl *file) readPart() error {
-	queryPart := "SELECT `data` FROM grid_part WHERE file_id = ? AND `serial` = ?"
-	var pt part
-	if err := fl.db.QueryRow(queryPart, fl.id, fl.serial).
-		Scan(&pt.data); err != nil {
-		fl.eof = true
-		return io.EOF
+	if len(fl.pending) == 0 {
+		if err := fl.fetch(); err != nil {
+			fl.eof = true
+			if err != io.EOF { // 已经读到的数据先返回，下次 Read 再返回错误
+				fl.err = err
+			}
+			return err
+		}
+	}
+
+	fl.buffer = fl.pending[0]
+	fl.pending[0] = nil
+	fl.pending = fl.pending[1:]
+	// 跳过 Seek 之前的内容，分片大小不固定时可能跨越多个分片
+	if skip := fl.skip; skip > 0 {
+		if size := int64(len(fl.buffer)); skip >= size {
+			fl.skip -= size
+			fl.buffer = nil
+		} else {
+			fl.skip = 0
+			fl.buffer = fl.buffer[skip:]
+		}
 	}
 
-	fl.serial++
-	fl.buffer = pt.data
+	return nil
+}
+
+// fetch 一次查询读取 prefetch 个连续的分片，读完时返回 io.EOF，存储出错时
+// 原样返回。分片大小固定时根据文件大小计算分片数，中途缺失分片返回
+// io.ErrUnexpectedEOF；旧版本写入的文件分片大小不固定，一直读到分片不存在为止。
+func (fl *file) fetch() error {
+	ctx := fl.context()
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	pid, first := fl.partID(), fl.serial
+	last := first + int64(fl.prefetch) - 1
+	missing := io.EOF // 分片不存在时返回的错误
+	if fl.fixed && fl.burst > 0 {
+		parts := (fl.filesize + int64(fl.burst) - 1) / int64(fl.burst)
+		if first >= parts {
+			return io.EOF
+		}
+		if last >= parts {
+			last = parts - 1
+		}
+		missing = io.ErrUnexpectedEOF
+	}
+	if last <= first {
+		data, err := fl.store.ReadPart(ctx, pid, first)
+		if err == fs.ErrNotExist {
+			return missing
+		}
+		if err != nil {
+			return err
+		}
+		if data, err = fl.pc.decode(pid, first, data); err != nil {
+			return err
+		}
+		fl.serial++
+		fl.pending = append(fl.pending[:0], data)
+		return nil
+	}
+
+	pending := make([][]byte, 0, last-first+1)
+	err := fl.store.ReadParts(ctx, pid, first, last, func(serial int64, data []byte) error {
+		if serial != first+int64(len(pending)) { // 分片缺失，之后的分片不再读取
+			return errPartGap
+		}
+		data, err := fl.pc.decode(pid, serial, data)
+		if err != nil {
+			return err
+		}
+		pending = append(pending, data)
+		return nil
+	})
+	if err != nil && err != errPartGap {
+		return err
+	}
+	if len(pending) == 0 {
+		return missing
+	}
+	fl.serial += int64(len(pending))
+	fl.pending = pending
 
 	return nil
 }
@@ -140,7 +386,7 @@ func (fl *file) readPart() error {
 // (
 //
 //	`id`      BIGINT AUTO_INCREMENT COMMENT '内容分片 ID',
-//	`file_id` BIGINT           NOT NULL COMMENT '所属文件 ID',
+//	`file_id` BIGINT           NOT NULL COMMENT '所属文件 ID（去重后为 grid_blob.id）',
 //	`serial`  BIGINT DEFAULT 0 NOT NULL COMMENT '分片序号 (0-n)',
 //	`data`    BLOB             NOT NULL COMMENT '分片内容',
 //	CONSTRAINT grid_part_pk PRIMARY KEY (`id`),
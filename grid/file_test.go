@@ -0,0 +1,152 @@
+package grid
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"testing"
+)
+
+// countStore 统计 ReadPart / ReadParts 的调用次数
+type countStore struct {
+	Store
+	calls int
+}
+
+func (cs *countStore) ReadPart(ctx context.Context, id, serial int64) ([]byte, error) {
+	cs.calls++
+	return cs.Store.ReadPart(ctx, id, serial)
+}
+
+func (cs *countStore) ReadParts(ctx context.Context, id, first, last int64, fn func(int64, []byte) error) error {
+	cs.calls++
+	return cs.Store.ReadParts(ctx, id, first, last, fn)
+}
+
+func TestFilePrefetch(t *testing.T) {
+	ds, err := NewDirStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	st := &countStore{Store: ds}
+
+	content := bytes.Repeat([]byte("0123456789abcdef"), 10)
+	burst := 16
+	for i := 0; i*burst < len(content); i++ {
+		if err = st.WritePart(context.Background(), 3, int64(i), content[i*burst:(i+1)*burst]); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	fl := &file{id: 3, filesize: int64(len(content)), burst: burst, fixed: true, store: st, prefetch: 4}
+	got, err := io.ReadAll(fl)
+	if err != nil || !bytes.Equal(got, content) {
+		t.Fatalf("read: %v", err)
+	}
+	if st.calls != 3 { // 4 + 4 + 2
+		t.Fatalf("unexpected store calls: %d", st.calls)
+	}
+
+	if _, err = fl.Seek(70, io.SeekStart); err != nil {
+		t.Fatal(err)
+	}
+	if got, err = io.ReadAll(fl); err != nil || !bytes.Equal(got, content[70:]) {
+		t.Fatalf("read after seek: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	fl = &file{id: 3, filesize: int64(len(content)), burst: burst, fixed: true, store: st, prefetch: 4, ctx: ctx}
+	p := make([]byte, 64)
+	if n, err := fl.Read(p); err != nil || n != 64 {
+		t.Fatalf("read before cancel: %d %v", n, err)
+	}
+	cancel()
+	if _, err = fl.Read(p); err != context.Canceled {
+		t.Fatalf("read after cancel: %v", err)
+	}
+}
+
+// failStore 读取分片总是失败
+type failStore struct {
+	Store
+	err error
+}
+
+func (fs *failStore) ReadPart(context.Context, int64, int64) ([]byte, error) {
+	return nil, fs.err
+}
+
+func (fs *failStore) ReadParts(context.Context, int64, int64, int64, func(int64, []byte) error) error {
+	return fs.err
+}
+
+// TestFileShortParts 旧版本写入的文件分片大小不固定
+func TestFileShortParts(t *testing.T) {
+	ds, err := NewDirStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	content := []byte("0123456789abcdefghijklmnopqrstuvwxyz")
+	sizes := []int{10, 4, 16, 6} // 分片数多于 ceil(size/burst)
+	var start int
+	for i, sz := range sizes {
+		if err = ds.WritePart(context.Background(), 5, int64(i), content[start:start+sz]); err != nil {
+			t.Fatal(err)
+		}
+		start += sz
+	}
+
+	fl := &file{id: 5, filesize: int64(len(content)), burst: 16, store: ds, prefetch: 2}
+	if got, err := io.ReadAll(fl); err != nil || !bytes.Equal(got, content) {
+		t.Fatalf("read: %q %v", got, err)
+	}
+
+	for _, off := range []int64{0, 5, 20, 26, 35} {
+		if _, err = fl.Seek(off, io.SeekStart); err != nil {
+			t.Fatal(err)
+		}
+		if got, err := io.ReadAll(fl); err != nil || !bytes.Equal(got, content[off:]) {
+			t.Fatalf("read after seek %d: %q %v", off, got, err)
+		}
+	}
+
+	p := make([]byte, 8)
+	if n, err := fl.ReadAt(p, 12); err != nil || !bytes.Equal(p[:n], content[12:20]) {
+		t.Fatalf("read at: %q %v", p[:n], err)
+	}
+	if n, err := fl.ReadAt(p, 30); err != io.EOF || !bytes.Equal(p[:n], content[30:]) {
+		t.Fatalf("read at end: %q %v", p[:n], err)
+	}
+}
+
+func TestFileReadError(t *testing.T) {
+	ds, err := NewDirStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	content := bytes.Repeat([]byte("0123456789abcdef"), 3)
+	for i := 0; i < 3; i++ {
+		if i == 1 { // 分片大小固定的文件缺少中间的分片
+			continue
+		}
+		if err = ds.WritePart(context.Background(), 6, int64(i), content[i*16:(i+1)*16]); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	fl := &file{id: 6, filesize: int64(len(content)), burst: 16, fixed: true, store: ds, prefetch: 4}
+	if _, err = io.ReadAll(fl); err != io.ErrUnexpectedEOF {
+		t.Fatalf("read missing part: %v", err)
+	}
+
+	// 存储出错不能当作读完
+	errStore := errors.New("s3 unavailable")
+	for _, prefetch := range []int{1, 4} {
+		fl = &file{id: 6, filesize: int64(len(content)), burst: 16, store: &failStore{Store: ds, err: errStore}, prefetch: prefetch}
+		if _, err = io.ReadAll(fl); err != errStore {
+			t.Fatalf("prefetch %d: unexpected error: %v", prefetch, err)
+		}
+	}
+}
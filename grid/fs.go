@@ -13,22 +13,108 @@ import (
 
 type FS interface {
 	fs.FS
+	Uploader
+	Deduper
+	Scrubber
+	Lister
 	OpenID(int64) (File, error)
 	Remove(int64) error
 	Write(io.Reader, string) (File, error)
+
+	// WriteWith 保存文件，并指定该文件分片的压缩与加密方式。
+	WriteWith(io.Reader, string, WriteOptions) (File, error)
+
+	// OpenIDContext 通过 ID 打开文件，返回的文件读取分片时使用该 ctx，
+	// ctx 取消后 Read 返回 ctx.Err()。
+	OpenIDContext(ctx context.Context, id int64) (File, error)
+
+	// WriteContext 保存文件，ctx 取消后停止写入并清理已写入的分片，
+	// opt 为空代表使用默认的写入参数。
+	WriteContext(ctx context.Context, r io.Reader, name string, opt *WriteOptions) (File, error)
+}
+
+// Option FS 参数
+type Option func(*gridFS)
+
+// WithStore 指定分片存储后端，默认保存在 MySQL grid_part 表中。
+func WithStore(st Store) Option {
+	return func(gfs *gridFS) {
+		if st != nil {
+			gfs.store = st
+		}
+	}
+}
+
+// WithKeyring 分片加密密钥，读写加密文件时使用。
+func WithKeyring(kr Keyring) Option {
+	return func(gfs *gridFS) {
+		gfs.keys = kr
+	}
+}
+
+// WithWriteOptions Write 与 CreateUpload 默认的压缩与加密方式。
+func WithWriteOptions(opt WriteOptions) Option {
+	return func(gfs *gridFS) {
+		gfs.wopt = opt
+	}
+}
+
+// WithPrefetch 顺序读取时每次查询的分片数，默认 8，为 1 代表不预读。
+func WithPrefetch(n int) Option {
+	return func(gfs *gridFS) {
+		if n > 0 {
+			gfs.prefetch = n
+		}
+	}
 }
 
-func NewFS(db *sql.DB) FS {
-	return &gridFS{db: db, burst: 60 * 1024}
+func NewFS(db *sql.DB, opts ...Option) FS {
+	gfs := &gridFS{db: db, store: NewMySQLStore(db), burst: 60 * 1024, prefetch: 8}
+	for _, fn := range opts {
+		fn(gfs)
+	}
+
+	return gfs
 }
 
 type gridFS struct {
-	db    *sql.DB // 数据库连接
-	burst int     // 60K
+	db       *sql.DB      // 数据库连接，保存文件信息
+	store    Store        // 分片存储
+	burst    int          // 60K
+	prefetch int          // 顺序读取时每次查询的分片数
+	keys     Keyring      // 分片加密密钥
+	wopt     WriteOptions // 默认的写入参数
+}
+
+// fileColumns 查询 grid_file 的字段，与 scanFile 一一对应。
+const fileColumns = "id, `name`, size, sha1, burst, done, blob_id, codec, key_id, fixed, created_at, updated_at"
+
+// scanFile 读取一行 fileColumns
+func (gfs *gridFS) scanFile(row interface{ Scan(...any) error }) (*file, error) {
+	fl := &file{store: gfs.store, prefetch: gfs.prefetch}
+	if err := row.Scan(&fl.id, &fl.filename, &fl.filesize, &fl.sha1, &fl.burst, &fl.done,
+		&fl.blobID, &fl.codec, &fl.keyID, &fl.fixed, &fl.createdAt, &fl.updatedAt); err != nil {
+		return nil, err
+	}
+	fl.pc = gfs.partCodec(fl.codec, fl.keyID)
+
+	return fl, nil
+}
+
+// partCodec 分片编解码器，密钥不可用时不影响查询文件信息，读取时才会报错。
+func (gfs *gridFS) partCodec(codec, keyID string) *partCodec {
+	pc, err := newPartCodec(codec, keyID, gfs.keys)
+	if err != nil {
+		return &partCodec{err: err}
+	}
+	return pc
 }
 
 // Open implement fs.FS
 func (gfs *gridFS) Open(id string) (fs.File, error) {
+	if id == "." {
+		return &rootDir{gfs: gfs}, nil
+	}
 	fid, err := strconv.ParseInt(id, 10, 64)
 	if err != nil {
 		return nil, fs.ErrInvalid
@@ -38,13 +124,22 @@ func (gfs *gridFS) Open(id string) (fs.File, error) {
 }
 
 func (gfs *gridFS) OpenID(id int64) (File, error) {
-	rawSQL := "SELECT id, `name`, size, sha1, burst, done, created_at, updated_at FROM grid_file WHERE id = ?"
-	row := gfs.db.QueryRow(rawSQL, id)
-	fl := &file{db: gfs.db}
-	if err := row.Scan(&fl.id, &fl.filename, &fl.filesize, &fl.sha1, &fl.burst,
-		&fl.done, &fl.createdAt, &fl.updatedAt); err != nil || !fl.done {
+	return gfs.OpenIDContext(context.Background(), id)
+}
+
+func (gfs *gridFS) OpenIDContext(ctx context.Context, id int64) (File, error) {
+	rawSQL := "SELECT " + fileColumns + " FROM grid_file WHERE id = ?"
+	fl, err := gfs.scanFile(gfs.db.QueryRowContext(ctx, rawSQL, id))
+	if err != nil {
+		if ce := ctx.Err(); ce != nil {
+			return nil, ce
+		}
 		return nil, fs.ErrNotExist
 	}
+	if !fl.done {
+		return nil, fs.ErrNotExist
+	}
+	fl.ctx = ctx
 
 	return fl, nil
 }
@@ -57,31 +152,59 @@ func (gfs *gridFS) Remove(id int64) error {
 	//goland:noinspection GoUnhandledErrorResult
 	defer tx.Rollback()
 
-	deleteFile := "DELETE FROM grid_file WHERE id = ?"
-	if _, err = tx.Exec(deleteFile, id); err == nil {
-		deletePart := "DELETE FROM grid_part WHERE file_id = ?"
-		if _, err = tx.Exec(deletePart, id); err == nil {
-			return tx.Commit()
+	var blobID int64
+	querySQL := "SELECT blob_id FROM grid_file WHERE id = ? FOR UPDATE"
+	if err = tx.QueryRow(querySQL, id).Scan(&blobID); err != nil {
+		if err == sql.ErrNoRows {
+			return nil
 		}
+		return err
+	}
+	if blobID == 0 {
+		blobID = id
 	}
 
-	return err
+	deleteFile := "DELETE FROM grid_file WHERE id = ?"
+	if _, err = tx.Exec(deleteFile, id); err != nil {
+		return err
+	}
+	deleteMeta := "DELETE FROM grid_meta WHERE file_id = ?"
+	if _, err = tx.Exec(deleteMeta, id); err != nil {
+		return err
+	}
+	// 共享的分片要等最后一个引用删除时才删除
+	drop, err := releaseBlob(tx, blobID)
+	if err != nil {
+		return err
+	}
+	if err = tx.Commit(); err != nil || !drop {
+		return err
+	}
+
+	return gfs.store.DeleteParts(context.Background(), blobID)
 }
 
 func (gfs *gridFS) Write(r io.Reader, name string) (File, error) {
-	// 开启事务
-	opt := &sql.TxOptions{Isolation: sql.LevelReadCommitted}
-	tx, err := gfs.db.BeginTx(context.Background(), opt)
+	return gfs.WriteWith(r, name, gfs.wopt)
+}
+
+func (gfs *gridFS) WriteWith(r io.Reader, name string, opt WriteOptions) (File, error) {
+	return gfs.WriteContext(context.Background(), r, name, &opt)
+}
+
+func (gfs *gridFS) WriteContext(ctx context.Context, r io.Reader, name string, opt *WriteOptions) (File, error) {
+	if opt == nil {
+		opt = &gfs.wopt
+	}
+	pc, err := newPartCodec(opt.Codec, opt.KeyID, gfs.keys)
 	if err != nil {
 		return nil, err
 	}
-	//goland:noinspection GoUnhandledErrorResult
-	defer tx.Rollback()
 
 	burst := gfs.burst
 	createdAt := time.Now()
-	insertFile := "INSERT INTO grid_file(`name`, sha1, burst, created_at) VALUE (?, ?, ?, ?)"
-	ret, err := tx.Exec(insertFile, name, "", burst, createdAt)
+	insertFile := "INSERT INTO grid_file(`name`, sha1, burst, codec, key_id, fixed, created_at) VALUE (?, ?, ?, ?, ?, ?, ?)"
+	ret, err := gfs.db.ExecContext(ctx, insertFile, name, "", burst, opt.Codec, opt.KeyID, true, createdAt)
 	if err != nil {
 		return nil, err
 	}
@@ -90,50 +213,105 @@ func (gfs *gridFS) Write(r io.Reader, name string) (File, error) {
 		return nil, err
 	}
 
-	insertPart := "INSERT INTO grid_part (file_id, `serial`, `data`) VALUE (?, ?, ?)"
+	// 分片写入存储后端，写入期间 done = 0，对 OpenID 不可见。使用 io.ReadFull
+	// 保证除最后一个分片外都是 burst 大小（fixed = 1），Seek 才能直接定位分片。
 	buf := make([]byte, burst)
-
 	checksum := sha1.New()
-	tr := io.TeeReader(r, checksum)
-
-	var n, serial int
-	var filesize int64
+	var serial, filesize int64
 	for {
-		if n, err = tr.Read(buf); err != nil {
-			if err == io.EOF {
-				err = nil
-			}
+		if err = ctx.Err(); err != nil {
 			break
 		}
-		if _, err = tx.Exec(insertPart, fileID, serial, buf[:n]); err != nil {
+		n, re := io.ReadFull(r, buf)
+		if n > 0 {
+			checksum.Write(buf[:n])
+			var data []byte
+			if data, err = pc.encode(fileID, serial, buf[:n]); err != nil {
+				break
+			}
+			if err = gfs.store.WritePart(ctx, fileID, serial, data); err != nil {
+				break
+			}
+			serial++
+			filesize += int64(n)
+		}
+		if re != nil {
+			if re != io.EOF && re != io.ErrUnexpectedEOF {
+				err = re
+			}
 			break
 		}
-		serial++
-		filesize += int64(n)
 	}
 
+	var blob *blobRef
+	sum := hex.EncodeToString(checksum.Sum(nil))
 	if err == nil {
-		sum := hex.EncodeToString(checksum.Sum(nil))
-		updatedAt := time.Now()
-		updateFile := "UPDATE grid_file SET size = ?, sha1 = ?, done = ?, updated_at = ? WHERE id = ?"
-		if _, err = tx.Exec(updateFile, filesize, sum, true, updatedAt, fileID); err == nil {
-			if err = tx.Commit(); err == nil {
-				fl := &file{
-					id:        fileID,
-					filename:  name,
-					filesize:  filesize,
-					sha1:      sum,
-					burst:     burst,
-					done:      true,
-					createdAt: createdAt,
-					updatedAt: updatedAt,
-					db:        gfs.db,
-				}
-
-				return fl, nil
-			}
-		}
+		blob, err = gfs.publish(ctx, fileID, filesize, sum, burst, *opt)
+	}
+	if err != nil {
+		// ctx 可能已经取消，清理时不能再使用它
+		gfs.discard(context.Background(), fileID)
+		return nil, err
 	}
 
-	return nil, err
+	fl := &file{
+		id:        fileID,
+		filename:  name,
+		filesize:  filesize,
+		sha1:      sum,
+		burst:     burst,
+		done:      true,
+		blobID:    blob.id,
+		codec:     opt.Codec,
+		keyID:     opt.KeyID,
+		fixed:     blob.fixed,
+		createdAt: createdAt,
+		updatedAt: blob.updatedAt,
+		store:     gfs.store,
+		pc:        pc,
+		prefetch:  gfs.prefetch,
+	}
+
+	return fl, nil
+}
+
+// publish 登记文件内容并将文件置为上传完毕，已经存在相同内容时复用已有的
+// 分片，并删除刚写入的分片。
+func (gfs *gridFS) publish(ctx context.Context, fileID, filesize int64, sum string, burst int, opt WriteOptions) (*blobRef, error) {
+	tx, err := gfs.db.BeginTx(ctx, &sql.TxOptions{})
+	if err != nil {
+		return nil, err
+	}
+	//goland:noinspection GoUnhandledErrorResult
+	defer tx.Rollback()
+
+	blob, err := shareBlob(tx, fileID, filesize, sum, burst, opt)
+	if err != nil {
+		return nil, err
+	}
+	// 复用旧版本写入的内容时，分片大小以已有的分片为准
+	blob.updatedAt = time.Now()
+	updateFile := "UPDATE grid_file SET size = ?, sha1 = ?, done = ?, blob_id = ?, fixed = ?, updated_at = ? WHERE id = ? AND done = ?"
+	ret, err := tx.Exec(updateFile, filesize, sum, true, blob.id, blob.fixed, blob.updatedAt, fileID, false)
+	if err != nil {
+		return nil, err
+	}
+	if n, _ := ret.RowsAffected(); n == 0 {
+		return nil, ErrUploadDone
+	}
+	if err = tx.Commit(); err != nil {
+		return nil, err
+	}
+	if blob.id != fileID {
+		_ = gfs.store.DeleteParts(ctx, fileID)
+	}
+
+	return blob, nil
+}
+
+// discard 写入失败时删除文件信息与已写入的分片
+func (gfs *gridFS) discard(ctx context.Context, fileID int64) {
+	deleteFile := "DELETE FROM grid_file WHERE id = ? AND done = ?"
+	_, _ = gfs.db.ExecContext(ctx, deleteFile, fileID, false)
+	_ = gfs.store.DeleteParts(ctx, fileID)
 }
@@ -0,0 +1,351 @@
+package grid
+
+import (
+	"context"
+	"database/sql"
+	"io"
+	"io/fs"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/vela-ssoc/backend-common/dynsql"
+)
+
+// meta 文件元数据（MySQL），如：上传者、用途（minion_bin、third）等。
+// CREATE TABLE `grid_meta`
+// (
+//
+//	`file_id` BIGINT       NOT NULL COMMENT '文件 ID',
+//	`key`     VARCHAR(64)  NOT NULL COMMENT '元数据名',
+//	`value`   VARCHAR(255) NOT NULL COMMENT '元数据值',
+//	CONSTRAINT grid_meta_pk PRIMARY KEY (`file_id`, `key`),
+//	INDEX grid_meta_key_value_index (`key`, `value`)
+//
+// ) COMMENT '文件元数据';
+
+// Lister 文件列表、查询与元数据
+type Lister interface {
+	// ReadDir 实现 fs.ReadDirFS，只支持根目录 "."，目录项的名字为文件 ID，
+	// 可以直接传给 Open，所以 fs.WalkDir 等标准库函数可以正常使用。
+	ReadDir(name string) ([]fs.DirEntry, error)
+
+	// Find 按条件分页查询文件
+	Find(ctx context.Context, q Query) (*FileList, error)
+
+	// Meta 查询文件的元数据
+	Meta(id int64) (map[string]string, error)
+
+	// SetMeta 设置文件的元数据，值为空代表删除该项。
+	SetMeta(id int64, meta map[string]string) error
+}
+
+// Query 文件查询条件，零值代表不限制。
+type Query struct {
+	Name     string            // 文件名模糊匹配
+	MinSize  int64             // 最小文件大小
+	MaxSize  int64             // 最大文件大小
+	Since    time.Time         // 创建时间下限（包含）
+	Until    time.Time         // 创建时间上限（不包含）
+	Meta     map[string]string // 元数据精确匹配，多个条件之间为 AND
+	Checksum string            // 文件 SHA-1
+	Pending  bool              // 是否包含未上传完毕的文件
+	Order    string            // 排序字段：id name size created_at updated_at，默认 id
+	Desc     bool              // 是否倒序
+	Page     int               // 页码，从 1 开始
+	Size     int               // 每页条数，默认 20，最大 1000
+}
+
+// FileList 分页查询结果
+type FileList struct {
+	Page    int           `json:"page"`
+	Size    int           `json:"size"`
+	Total   int64         `json:"total"`
+	Records []*FileRecord `json:"records"`
+}
+
+// FileRecord 文件信息，便于直接序列化给前端。
+type FileRecord struct {
+	ID        int64             `json:"id,string"`
+	Name      string            `json:"name"`
+	Size      int64             `json:"size"`
+	Checksum  string            `json:"checksum"`
+	Done      bool              `json:"done"`
+	Meta      map[string]string `json:"meta"`
+	CreatedAt time.Time         `json:"created_at"`
+	UpdatedAt time.Time         `json:"updated_at"`
+}
+
+var queryOrders = map[string]string{
+	"":           "id",
+	"id":         "id",
+	"name":       "`name`",
+	"size":       "size",
+	"created_at": "created_at",
+	"updated_at": "updated_at",
+}
+
+func (gfs *gridFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	if name != "." {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: fs.ErrNotExist}
+	}
+
+	rawSQL := "SELECT " + fileColumns + " FROM grid_file WHERE done = ? ORDER BY id"
+	rows, err := gfs.db.Query(rawSQL, true)
+	if err != nil {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: err}
+	}
+	//goland:noinspection GoUnhandledErrorResult
+	defer rows.Close()
+
+	entries := make([]fs.DirEntry, 0, 64)
+	for rows.Next() {
+		fl, err := gfs.scanFile(rows)
+		if err != nil {
+			return nil, &fs.PathError{Op: "readdir", Path: name, Err: err}
+		}
+		entries = append(entries, &dirEntry{fl: fl})
+	}
+	if err = rows.Err(); err != nil {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: err}
+	}
+
+	return entries, nil
+}
+
+func (gfs *gridFS) Find(ctx context.Context, q Query) (*FileList, error) {
+	order, ok := queryOrders[q.Order]
+	if !ok {
+		return nil, fs.ErrInvalid
+	}
+	if q.Page <= 0 {
+		q.Page = 1
+	}
+	if q.Size <= 0 {
+		q.Size = 20
+	} else if q.Size > 1000 {
+		q.Size = 1000
+	}
+
+	where := make([]string, 0, 8)
+	args := make([]any, 0, 8)
+	if !q.Pending {
+		where = append(where, "done = ?")
+		args = append(args, true)
+	}
+	if q.Name != "" {
+		where = append(where, "`name` LIKE ?")
+		args = append(args, "%"+dynsql.EscapeLike(q.Name)+"%")
+	}
+	if q.Checksum != "" {
+		where = append(where, "sha1 = ?")
+		args = append(args, strings.ToLower(q.Checksum))
+	}
+	if q.MinSize > 0 {
+		where = append(where, "size >= ?")
+		args = append(args, q.MinSize)
+	}
+	if q.MaxSize > 0 {
+		where = append(where, "size <= ?")
+		args = append(args, q.MaxSize)
+	}
+	if !q.Since.IsZero() {
+		where = append(where, "created_at >= ?")
+		args = append(args, q.Since)
+	}
+	if !q.Until.IsZero() {
+		where = append(where, "created_at < ?")
+		args = append(args, q.Until)
+	}
+	for k, v := range q.Meta {
+		where = append(where, "EXISTS (SELECT 1 FROM grid_meta m WHERE m.file_id = grid_file.id AND m.`key` = ? AND m.`value` = ?)")
+		args = append(args, k, v)
+	}
+	var cond string
+	if len(where) != 0 {
+		cond = " WHERE " + strings.Join(where, " AND ")
+	}
+
+	ret := &FileList{Page: q.Page, Size: q.Size, Records: []*FileRecord{}}
+	countSQL := "SELECT COUNT(*) FROM grid_file" + cond
+	if err := gfs.db.QueryRowContext(ctx, countSQL, args...).Scan(&ret.Total); err != nil {
+		return nil, err
+	}
+	offset := int64(q.Page-1) * int64(q.Size)
+	if ret.Total <= offset {
+		return ret, nil
+	}
+
+	if q.Desc {
+		order += " DESC, id DESC"
+	} else if order != "id" {
+		order += ", id"
+	}
+	querySQL := "SELECT id, `name`, size, sha1, done, created_at, updated_at FROM grid_file" + cond +
+		" ORDER BY " + order + " LIMIT ? OFFSET ?"
+	rows, err := gfs.db.QueryContext(ctx, querySQL, append(args, q.Size, offset)...)
+	if err != nil {
+		return nil, err
+	}
+	//goland:noinspection GoUnhandledErrorResult
+	defer rows.Close()
+
+	index := make(map[int64]*FileRecord, q.Size)
+	ids := make([]any, 0, q.Size)
+	for rows.Next() {
+		rec := &FileRecord{Meta: map[string]string{}}
+		if err = rows.Scan(&rec.ID, &rec.Name, &rec.Size, &rec.Checksum, &rec.Done,
+			&rec.CreatedAt, &rec.UpdatedAt); err != nil {
+			return nil, err
+		}
+		ret.Records = append(ret.Records, rec)
+		index[rec.ID] = rec
+		ids = append(ids, rec.ID)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(ids) == 0 {
+		return ret, nil
+	}
+
+	// 一次查询出本页所有文件的元数据
+	metaSQL := "SELECT file_id, `key`, `value` FROM grid_meta WHERE file_id IN (?" +
+		strings.Repeat(", ?", len(ids)-1) + ")"
+	mrows, err := gfs.db.QueryContext(ctx, metaSQL, ids...)
+	if err != nil {
+		return nil, err
+	}
+	//goland:noinspection GoUnhandledErrorResult
+	defer mrows.Close()
+
+	var fileID int64
+	var key, value string
+	for mrows.Next() {
+		if err = mrows.Scan(&fileID, &key, &value); err != nil {
+			return nil, err
+		}
+		if rec := index[fileID]; rec != nil {
+			rec.Meta[key] = value
+		}
+	}
+
+	return ret, mrows.Err()
+}
+
+func (gfs *gridFS) Meta(id int64) (map[string]string, error) {
+	querySQL := "SELECT `key`, `value` FROM grid_meta WHERE file_id = ?"
+	rows, err := gfs.db.Query(querySQL, id)
+	if err != nil {
+		return nil, err
+	}
+	//goland:noinspection GoUnhandledErrorResult
+	defer rows.Close()
+
+	meta := make(map[string]string, 8)
+	var key, value string
+	for rows.Next() {
+		if err = rows.Scan(&key, &value); err != nil {
+			return nil, err
+		}
+		meta[key] = value
+	}
+
+	return meta, rows.Err()
+}
+
+func (gfs *gridFS) SetMeta(id int64, meta map[string]string) error {
+	for k, v := range meta {
+		if k == "" || len(k) > 64 || len(v) > 255 {
+			return fs.ErrInvalid
+		}
+	}
+
+	tx, err := gfs.db.BeginTx(context.Background(), &sql.TxOptions{})
+	if err != nil {
+		return err
+	}
+	//goland:noinspection GoUnhandledErrorResult
+	defer tx.Rollback()
+
+	var fileID int64
+	querySQL := "SELECT id FROM grid_file WHERE id = ? FOR UPDATE"
+	if err = tx.QueryRow(querySQL, id).Scan(&fileID); err != nil {
+		if err == sql.ErrNoRows {
+			return fs.ErrNotExist
+		}
+		return err
+	}
+
+	deleteMeta := "DELETE FROM grid_meta WHERE file_id = ? AND `key` = ?"
+	upsertMeta := "INSERT INTO grid_meta (file_id, `key`, `value`) VALUE (?, ?, ?) " +
+		"ON DUPLICATE KEY UPDATE `value` = VALUES(`value`)"
+	for k, v := range meta {
+		if v == "" {
+			_, err = tx.Exec(deleteMeta, id, k)
+		} else {
+			_, err = tx.Exec(upsertMeta, id, k, v)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// dirEntry 目录项，名字为文件 ID。
+type dirEntry struct {
+	fl *file
+}
+
+func (de *dirEntry) Name() string               { return strconv.FormatInt(de.fl.id, 10) }
+func (de *dirEntry) IsDir() bool                { return false }
+func (de *dirEntry) Type() fs.FileMode          { return 0 }
+func (de *dirEntry) Info() (fs.FileInfo, error) { return de.fl, nil }
+
+// rootDir Open(".") 返回的根目录
+type rootDir struct {
+	gfs     Lister
+	entries []fs.DirEntry
+	loaded  bool
+}
+
+func (rd *rootDir) Stat() (fs.FileInfo, error) { return rd, nil }
+func (rd *rootDir) Read([]byte) (int, error) {
+	return 0, &fs.PathError{Op: "read", Path: ".", Err: fs.ErrInvalid}
+}
+func (rd *rootDir) Close() error       { return nil }
+func (rd *rootDir) Name() string       { return "." }
+func (rd *rootDir) Size() int64        { return 0 }
+func (rd *rootDir) Mode() fs.FileMode  { return fs.ModeDir | 0o555 }
+func (rd *rootDir) ModTime() time.Time { return time.Time{} }
+func (rd *rootDir) IsDir() bool        { return true }
+func (rd *rootDir) Sys() any           { return nil }
+
+// ReadDir 实现 fs.ReadDirFile
+func (rd *rootDir) ReadDir(n int) ([]fs.DirEntry, error) {
+	if !rd.loaded {
+		entries, err := rd.gfs.ReadDir(".")
+		if err != nil {
+			return nil, err
+		}
+		rd.entries, rd.loaded = entries, true
+	}
+
+	if n <= 0 {
+		entries := rd.entries
+		rd.entries = nil
+		return entries, nil
+	}
+	if len(rd.entries) == 0 {
+		return nil, io.EOF
+	}
+	if n > len(rd.entries) {
+		n = len(rd.entries)
+	}
+	entries := rd.entries[:n]
+	rd.entries = rd.entries[n:]
+
+	return entries, nil
+}
@@ -0,0 +1,190 @@
+package grid
+
+import (
+	"context"
+	"crypto/sha1"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"io/fs"
+	"math"
+	"strconv"
+)
+
+// ErrMigrateChecksum 迁移后的内容与 grid_file.sha1 不一致
+var ErrMigrateChecksum = errors.New("grid: migrate checksum mismatch")
+
+// MigrateOptions 迁移参数
+type MigrateOptions struct {
+	// DeleteSource 每个文件迁移并校验成功后删除源存储中的分片
+	DeleteSource bool
+
+	// Keyring 校验加密文件时使用的密钥，为空时加密的文件只复制不校验
+	Keyring Keyring
+
+	// OnFile 每个文件迁移完毕后回调，err 为空代表迁移成功
+	OnFile func(id int64, parts int64, err error)
+}
+
+// MigrateReport 迁移结果
+type MigrateReport struct {
+	Files  int64           `json:"files"`  // 迁移成功的分片组数（共享内容只算一次）
+	Parts  int64           `json:"parts"`  // 迁移的分片数
+	Bytes  int64           `json:"bytes"`  // 迁移的字节数
+	Failed map[int64]error `json:"-"`      // 迁移失败的分片组
+	Errors []string        `json:"errors"` // 失败原因，便于输出
+}
+
+// Migrate 将 grid_file 中登记的所有文件分片从 src 复制到 dst，文件 ID 与
+// 校验码保持不变，迁移完成后使用 WithStore(dst) 即可切换存储后端，调用方
+// 无需修改代码。已上传完毕的文件会重新计算 SHA-1 与 grid_file.sha1 比对，
+// 未提交的上传会话按原样复制已上传的分片。
+//
+// 迁移期间应停止文件上传。迁移是幂等的，中途失败可以重新执行，已迁移的
+// 分片会被覆盖。
+func Migrate(ctx context.Context, db *sql.DB, src, dst Store, opt MigrateOptions) (*MigrateReport, error) {
+	rawSQL := "SELECT id, size, sha1, burst, done, blob_id, codec, key_id, fixed FROM grid_file ORDER BY id"
+	rows, err := db.QueryContext(ctx, rawSQL)
+	if err != nil {
+		return nil, err
+	}
+	entries := make([]*migrateEntry, 0, 64)
+	seen := make(map[int64]bool, 64)
+	for rows.Next() {
+		var ent migrateEntry
+		var blobID int64
+		if err = rows.Scan(&ent.id, &ent.size, &ent.sum, &ent.burst, &ent.done, &blobID,
+			&ent.codec, &ent.keyID, &ent.fixed); err != nil {
+			_ = rows.Close()
+			return nil, err
+		}
+		if blobID != 0 {
+			ent.id = blobID
+		}
+		if seen[ent.id] { // 去重后共享的分片只迁移一次
+			continue
+		}
+		seen[ent.id] = true
+		entries = append(entries, &ent)
+	}
+	_ = rows.Close()
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	report := &MigrateReport{Failed: make(map[int64]error)}
+	for _, ent := range entries {
+		if err = ctx.Err(); err != nil {
+			return report, err
+		}
+
+		parts, size, err := migrateParts(ctx, src, dst, ent, opt.Keyring)
+		if err == nil && opt.DeleteSource {
+			err = src.DeleteParts(ctx, ent.id)
+		}
+
+		if err != nil {
+			report.Failed[ent.id] = err
+			report.Errors = append(report.Errors, strconv.FormatInt(ent.id, 10)+": "+err.Error())
+		} else {
+			report.Files++
+			report.Parts += parts
+			report.Bytes += size
+		}
+		if fn := opt.OnFile; fn != nil {
+			fn(ent.id, parts, err)
+		}
+	}
+
+	return report, nil
+}
+
+// migrateEntry 要迁移的一组分片
+type migrateEntry struct {
+	id    int64 // 分片所属 ID
+	size  int64
+	sum   string
+	burst int
+	done  bool
+	codec string
+	keyID string
+	fixed bool
+}
+
+// migrateParts 复制一组分片，已上传完毕的文件同时校验内容，返回复制的分片数与字节数。
+func migrateParts(ctx context.Context, src, dst Store, ent *migrateEntry, keys Keyring) (int64, int64, error) {
+	// 分片按原样复制（文件 ID 不变，加密的附加数据仍然有效），只在校验时解码
+	verify := ent.done
+	pc, err := newPartCodec(ent.codec, ent.keyID, keys)
+	if err == ErrKeyNotFound && keys == nil {
+		verify, err = false, nil
+	}
+	if err != nil {
+		return 0, 0, err
+	}
+
+	checksum := sha1.New()
+	var parts, size int64
+	copyPart := func(serial int64, data []byte) error {
+		if err := dst.WritePart(ctx, ent.id, serial, data); err != nil {
+			return err
+		}
+		parts++
+		if verify {
+			plain, err := pc.decode(ent.id, serial, data)
+			if err != nil {
+				return err
+			}
+			checksum.Write(plain)
+			size += int64(len(plain))
+		} else {
+			size += int64(len(data))
+		}
+		return nil
+	}
+
+	if !ent.done {
+		// 未提交的上传会话可能缺少中间的分片，按已存在的分片逐个复制
+		return parts, size, migrateSerials(ctx, src, ent.id, copyPart)
+	}
+
+	// 已上传完毕的文件分片是连续的，分片大小不固定（旧版本写入）时无法计算
+	// 分片数，一直读到分片不存在为止
+	last := int64(math.MaxInt64)
+	if ent.fixed && ent.burst > 0 {
+		last = (ent.size+int64(ent.burst)-1)/int64(ent.burst) - 1
+	}
+	if err = src.ReadParts(ctx, ent.id, 0, last, copyPart); err != nil {
+		return parts, size, err
+	}
+	if last != math.MaxInt64 && parts <= last {
+		return parts, size, &IncompleteError{Missing: []int64{parts}}
+	}
+	if verify && (size != ent.size || hex.EncodeToString(checksum.Sum(nil)) != ent.sum) {
+		return parts, size, ErrMigrateChecksum
+	}
+
+	return parts, size, nil
+}
+
+// migrateSerials 逐个复制已存在的分片
+func migrateSerials(ctx context.Context, src Store, id int64, fn func(int64, []byte) error) error {
+	serials, err := src.Serials(ctx, id)
+	if err != nil {
+		return err
+	}
+	for _, serial := range serials {
+		data, err := src.ReadPart(ctx, id, serial)
+		if err == fs.ErrNotExist {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if err = fn(serial, data); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
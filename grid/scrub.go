@@ -0,0 +1,340 @@
+package grid
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"strconv"
+	"time"
+)
+
+// Scrubber 存储维护：清理过期的上传会话与孤立分片、修正引用计数、检查
+// 分片缺失并校验文件内容。
+type Scrubber interface {
+	Scrub(ctx context.Context, opt ScrubOptions) (*ScrubReport, error)
+}
+
+// ScrubOptions 维护参数
+type ScrubOptions struct {
+	AbandonAfter time.Duration // 未提交的上传会话超过该时长视为废弃，默认 24h
+	Verify       bool          // 是否重新计算 SHA-1 与 grid_file.sha1 比对（需要读取全部分片）
+	DryRun       bool          // 只检查不删除、不修正
+}
+
+// ScrubIssue 损坏的文件内容，分片缺失或校验不一致
+type ScrubIssue struct {
+	PartID  int64   `json:"part_id,string"`    // 分片所属 ID
+	FileIDs []int64 `json:"file_ids"`          // 引用该内容的文件
+	Missing []int64 `json:"missing,omitempty"` // 缺失的分片序号
+	Reason  string  `json:"reason"`            // 损坏原因
+}
+
+// ScrubReport 维护结果
+type ScrubReport struct {
+	StartedAt  time.Time     `json:"started_at"`
+	FinishedAt time.Time     `json:"finished_at"`
+	DryRun     bool          `json:"dry_run"`
+	Abandoned  []int64       `json:"abandoned"` // 清理的过期上传会话
+	Orphans    []int64       `json:"orphans"`   // 清理的孤立分片（所属文件已经不存在）
+	Refs       []int64       `json:"refs"`      // 修正了引用计数的内容 ID
+	Checked    int64         `json:"checked"`   // 检查的文件内容数（共享内容只算一次）
+	Verified   int64         `json:"verified"`  // 校验通过的文件内容数
+	Damaged    []*ScrubIssue `json:"damaged"`   // 损坏的文件内容
+	Errors     []string      `json:"errors"`    // 执行过程中的错误，不影响其它检查项
+}
+
+func (r *ScrubReport) errorf(id int64, err error) {
+	r.Errors = append(r.Errors, strconv.FormatInt(id, 10)+": "+err.Error())
+}
+
+// Scrub 依次执行：
+//  1. 删除创建时间早于 AbandonAfter 仍未提交的上传会话
+//  2. 按照 grid_file.blob_id 重新统计 grid_blob.refs，不一致的予以修正
+//  3. 删除所属文件已经不存在的分片
+//  4. 检查已上传完毕的文件是否有分片缺失，Verify 时重新计算 SHA-1
+//
+// 损坏的文件无法修复，只会出现在报告中。单个文件出错不会中断维护，错误
+// 记录在 ScrubReport.Errors 中，只有查询文件信息失败时才返回 error。
+func (gfs *gridFS) Scrub(ctx context.Context, opt ScrubOptions) (*ScrubReport, error) {
+	if opt.AbandonAfter <= 0 {
+		opt.AbandonAfter = 24 * time.Hour
+	}
+	report := &ScrubReport{StartedAt: time.Now(), DryRun: opt.DryRun}
+	defer func() { report.FinishedAt = time.Now() }()
+
+	if err := gfs.scrubAbandoned(ctx, opt, report); err != nil {
+		return report, err
+	}
+	if err := gfs.scrubRefs(ctx, opt, report); err != nil {
+		return report, err
+	}
+
+	// 先列出存储中的分片再查询文件信息：写入时总是先插入 grid_file 再写分片，
+	// 这样正在写入的文件不会被误判为孤立分片。
+	stored, err := gfs.store.IDs(ctx)
+	if err != nil {
+		return report, err
+	}
+
+	referenced := make(map[int64]bool, 64)
+	contents := make(map[int64]*scrubContent, 64)
+	order := make([]int64, 0, 64)
+
+	rawSQL := "SELECT id, size, sha1, burst, done, blob_id, codec, key_id, fixed FROM grid_file ORDER BY id"
+	rows, err := gfs.db.QueryContext(ctx, rawSQL)
+	if err != nil {
+		return report, err
+	}
+	for rows.Next() {
+		var id, size, blobID int64
+		var sum, codec, keyID string
+		var burst int
+		var done, fixed bool
+		if err = rows.Scan(&id, &size, &sum, &burst, &done, &blobID, &codec, &keyID, &fixed); err != nil {
+			_ = rows.Close()
+			return report, err
+		}
+		pid := id
+		if blobID != 0 {
+			pid = blobID
+		}
+		referenced[pid] = true
+		if !done {
+			continue
+		}
+		if ct, ok := contents[pid]; ok {
+			ct.files = append(ct.files, id)
+			continue
+		}
+		pc := gfs.partCodec(codec, keyID)
+		contents[pid] = &scrubContent{size: size, sum: sum, burst: burst, fixed: fixed, pc: pc, files: []int64{id}}
+		order = append(order, pid)
+	}
+	_ = rows.Close()
+	if err = rows.Err(); err != nil {
+		return report, err
+	}
+
+	for _, id := range stored {
+		if referenced[id] {
+			continue
+		}
+		if !opt.DryRun {
+			if err = gfs.store.DeleteParts(ctx, id); err != nil {
+				report.errorf(id, err)
+				continue
+			}
+		}
+		report.Orphans = append(report.Orphans, id)
+	}
+
+	for _, pid := range order {
+		if err = ctx.Err(); err != nil {
+			return report, err
+		}
+		ct := contents[pid]
+		report.Checked++
+		issue, err := ct.check(ctx, gfs.store, pid, opt.Verify)
+		if err != nil {
+			report.errorf(pid, err)
+			continue
+		}
+		if issue != nil {
+			report.Damaged = append(report.Damaged, issue)
+		} else if opt.Verify {
+			report.Verified++
+		}
+	}
+
+	return report, nil
+}
+
+// scrubContent 已上传完毕的文件内容，去重后多个文件共享同一组分片
+type scrubContent struct {
+	size  int64
+	sum   string
+	burst int
+	fixed bool // 分片大小是否固定
+	pc    *partCodec
+	files []int64
+}
+
+// check 检查分片是否缺失，verify 时重新计算 SHA-1，内容完好时返回 nil。
+// 分片大小固定时分片数由文件大小决定；旧版本写入的文件分片大小不固定，
+// 只检查分片是否从 0 开始连续，末尾缺失的分片要 verify 时才能发现。
+func (ct *scrubContent) check(ctx context.Context, st Store, pid int64, verify bool) (*ScrubIssue, error) {
+	serials, err := st.Serials(ctx, pid)
+	if err != nil {
+		return nil, err
+	}
+	var parts int64
+	if ct.fixed && ct.burst > 0 {
+		parts = (ct.size + int64(ct.burst) - 1) / int64(ct.burst)
+	} else if n := len(serials); n != 0 {
+		parts = serials[n-1] + 1
+	} else if ct.size > 0 {
+		parts = 1
+	}
+
+	issue := &ScrubIssue{PartID: pid, FileIDs: ct.files}
+	if issue.Missing = missingSerials(serials, parts); len(issue.Missing) != 0 {
+		issue.Reason = "missing parts"
+		return issue, nil
+	}
+	if !verify {
+		return nil, nil
+	}
+
+	checksum := sha1.New()
+	var size int64
+	err = st.ReadParts(ctx, pid, 0, parts-1, func(serial int64, data []byte) error {
+		data, err := ct.pc.decode(pid, serial, data)
+		if err != nil {
+			return err
+		}
+		size += int64(len(data))
+		checksum.Write(data)
+		return nil
+	})
+	if err == ErrPartCorrupted {
+		issue.Reason = "part corrupted"
+		return issue, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case size != ct.size:
+		issue.Reason = "size mismatch"
+	case hex.EncodeToString(checksum.Sum(nil)) != ct.sum:
+		issue.Reason = "checksum mismatch"
+	default:
+		return nil, nil
+	}
+
+	return issue, nil
+}
+
+// scrubAbandoned 删除过期的上传会话
+func (gfs *gridFS) scrubAbandoned(ctx context.Context, opt ScrubOptions, report *ScrubReport) error {
+	before := time.Now().Add(-opt.AbandonAfter)
+	querySQL := "SELECT id FROM grid_file WHERE done = ? AND created_at < ?"
+	rows, err := gfs.db.QueryContext(ctx, querySQL, false, before)
+	if err != nil {
+		return err
+	}
+	ids := make([]int64, 0, 8)
+	for rows.Next() {
+		var id int64
+		if err = rows.Scan(&id); err != nil {
+			_ = rows.Close()
+			return err
+		}
+		ids = append(ids, id)
+	}
+	_ = rows.Close()
+	if err = rows.Err(); err != nil {
+		return err
+	}
+
+	for _, id := range ids {
+		if !opt.DryRun {
+			if err = gfs.Remove(id); err != nil {
+				report.errorf(id, err)
+				continue
+			}
+		}
+		report.Abandoned = append(report.Abandoned, id)
+	}
+
+	return nil
+}
+
+// scrubRefs 按照 grid_file.blob_id 修正 grid_blob.refs，已经没有引用的内容
+// 直接删除，其分片随后作为孤立分片清理。
+func (gfs *gridFS) scrubRefs(ctx context.Context, opt ScrubOptions, report *ScrubReport) error {
+	querySQL := "SELECT b.id, b.refs, COUNT(f.id) FROM grid_blob b " +
+		"LEFT JOIN grid_file f ON f.blob_id = b.id GROUP BY b.id, b.refs"
+	rows, err := gfs.db.QueryContext(ctx, querySQL)
+	if err != nil {
+		return err
+	}
+	fixes := make(map[int64]int64, 8)
+	for rows.Next() {
+		var id, refs, count int64
+		if err = rows.Scan(&id, &refs, &count); err != nil {
+			_ = rows.Close()
+			return err
+		}
+		if refs != count {
+			fixes[id] = count
+		}
+	}
+	_ = rows.Close()
+	if err = rows.Err(); err != nil {
+		return err
+	}
+
+	for id, count := range fixes {
+		if !opt.DryRun {
+			if count == 0 {
+				deleteBlob := "DELETE FROM grid_blob WHERE id = ? " +
+					"AND NOT EXISTS (SELECT 1 FROM grid_file WHERE blob_id = ?)"
+				_, err = gfs.db.ExecContext(ctx, deleteBlob, id, id)
+			} else {
+				updateBlob := "UPDATE grid_blob SET refs = (SELECT COUNT(*) FROM grid_file WHERE blob_id = ?) WHERE id = ?"
+				_, err = gfs.db.ExecContext(ctx, updateBlob, id, id)
+			}
+			if err != nil {
+				report.errorf(id, err)
+				continue
+			}
+		}
+		report.Refs = append(report.Refs, id)
+	}
+
+	return nil
+}
+
+// missingSerials 找出 [0, parts) 中缺失的分片序号
+func missingSerials(serials []int64, parts int64) []int64 {
+	var missing []int64
+	var next int64
+	for _, serial := range serials {
+		if serial >= parts {
+			break
+		}
+		for ; next < serial; next++ {
+			missing = append(missing, next)
+		}
+		next = serial + 1
+	}
+	for ; next < parts; next++ {
+		missing = append(missing, next)
+	}
+
+	return missing
+}
+
+// ScrubTask 将 Scrub 包装为 queue.Runner，配合 queue.Daily 即可每天定时执行。
+type ScrubTask struct {
+	FS      Scrubber                  // 文件系统
+	Options ScrubOptions              // 维护参数
+	Timeout time.Duration             // 单次执行的超时时间，小于等于 0 代表不限制
+	Report  func(*ScrubReport, error) // 执行完毕后回调，可以为空
+}
+
+// Run implement queue.Runner
+func (st *ScrubTask) Run() {
+	ctx := context.Background()
+	if st.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, st.Timeout)
+		defer cancel()
+	}
+
+	report, err := st.FS.Scrub(ctx, st.Options)
+	if fn := st.Report; fn != nil {
+		fn(report, err)
+	}
+}
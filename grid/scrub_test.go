@@ -0,0 +1,159 @@
+package grid
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"io/fs"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+)
+
+// memStore 内存中的 Store
+type memStore struct {
+	mu    sync.Mutex
+	parts map[int64]map[int64][]byte
+}
+
+func newMemStore() *memStore {
+	return &memStore{parts: make(map[int64]map[int64][]byte)}
+}
+
+func (ms *memStore) ReadPart(_ context.Context, id, serial int64) ([]byte, error) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	data, ok := ms.parts[id][serial]
+	if !ok {
+		return nil, fs.ErrNotExist
+	}
+	return data, nil
+}
+
+func (ms *memStore) ReadParts(ctx context.Context, id, first, last int64, fn func(int64, []byte) error) error {
+	return readParts(ctx, ms.ReadPart, id, first, last, fn)
+}
+
+func (ms *memStore) WritePart(_ context.Context, id, serial int64, data []byte) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	if ms.parts[id] == nil {
+		ms.parts[id] = make(map[int64][]byte)
+	}
+	ms.parts[id][serial] = data
+	return nil
+}
+
+func (ms *memStore) Serials(_ context.Context, id int64) ([]int64, error) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	serials := make([]int64, 0, len(ms.parts[id]))
+	for serial := range ms.parts[id] {
+		serials = append(serials, serial)
+	}
+	sort.Slice(serials, func(i, j int) bool { return serials[i] < serials[j] })
+	return serials, nil
+}
+
+func (ms *memStore) DeleteParts(_ context.Context, id int64) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	delete(ms.parts, id)
+	return nil
+}
+
+func (ms *memStore) IDs(context.Context) ([]int64, error) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	ids := make([]int64, 0, len(ms.parts))
+	for id := range ms.parts {
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+func TestScrubContent(t *testing.T) {
+	ctx := context.Background()
+	st := newMemStore()
+	content := []byte("0123456789abcdefghijklmnopqrstuvwxyz")
+	digest := sha1.Sum(content)
+	sum := hex.EncodeToString(digest[:])
+	write := func(id int64, sizes ...int) {
+		var start int
+		for i, sz := range sizes {
+			_ = st.WritePart(ctx, id, int64(i), content[start:start+sz])
+			start += sz
+		}
+	}
+	write(1, 16, 16, 4)    // 分片大小固定
+	write(2, 16, 16)       // 分片大小固定，缺少末尾的分片
+	write(3, 10, 4, 16, 6) // 旧版本写入，分片大小不固定
+	write(4, 10, 4, 16, 6) // 缺少中间的分片
+	delete(st.parts[4], 1)
+	write(5, 10, 4, 16) // 缺少末尾的分片，只有校验内容时才能发现
+
+	tests := []struct {
+		id     int64
+		fixed  bool
+		verify bool
+		reason string
+	}{
+		{id: 1, fixed: true, verify: true},
+		{id: 2, fixed: true, reason: "missing parts"},
+		{id: 3, verify: true},
+		{id: 4, reason: "missing parts"},
+		{id: 5},
+		{id: 5, verify: true, reason: "size mismatch"},
+		{id: 6, reason: "missing parts"}, // 没有任何分片
+	}
+	for _, tt := range tests {
+		ct := &scrubContent{size: int64(len(content)), sum: sum, burst: 16, fixed: tt.fixed, files: []int64{tt.id}}
+		issue, err := ct.check(ctx, st, tt.id, tt.verify)
+		if err != nil {
+			t.Fatalf("%d: %v", tt.id, err)
+		}
+		var reason string
+		if issue != nil {
+			reason = issue.Reason
+		}
+		if reason != tt.reason {
+			t.Errorf("%d fixed=%v verify=%v: got %q, want %q", tt.id, tt.fixed, tt.verify, reason, tt.reason)
+		}
+	}
+}
+
+// scrubFunc 将函数包装为 Scrubber
+type scrubFunc func(context.Context, ScrubOptions) (*ScrubReport, error)
+
+func (fn scrubFunc) Scrub(ctx context.Context, opt ScrubOptions) (*ScrubReport, error) {
+	return fn(ctx, opt)
+}
+
+func TestScrubTask(t *testing.T) {
+	var reported bool
+	task := &ScrubTask{
+		FS: scrubFunc(func(ctx context.Context, opt ScrubOptions) (*ScrubReport, error) {
+			if _, ok := ctx.Deadline(); !ok {
+				t.Error("expected timeout ctx")
+			}
+			if !opt.Verify {
+				t.Error("options not passed")
+			}
+			<-ctx.Done()
+			return &ScrubReport{Checked: 1}, ctx.Err()
+		}),
+		Options: ScrubOptions{Verify: true},
+		Timeout: 10 * time.Millisecond,
+		Report: func(r *ScrubReport, err error) {
+			reported = true
+			if r.Checked != 1 || err != context.DeadlineExceeded {
+				t.Errorf("unexpected report: %+v %v", r, err)
+			}
+		},
+	}
+	task.Run()
+	if !reported {
+		t.Fatal("report not called")
+	}
+}
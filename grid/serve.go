@@ -0,0 +1,101 @@
+package grid
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/vela-ssoc/backend-common/problem"
+)
+
+// NewHandler 通过 ID 下载文件的 http.Handler，文件 ID 取自 query 参数 param
+// （为空时默认为 id），支持 Range 断点续传、ETag 与 If-None-Match。
+func NewHandler(gfs FS, param string) http.Handler {
+	if param == "" {
+		param = "id"
+	}
+	return &handler{gfs: gfs, param: param}
+}
+
+type handler struct {
+	gfs   FS
+	param string
+}
+
+func (h *handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.URL.Query().Get(h.param), 10, 64)
+	if err != nil {
+		serveError(w, r, http.StatusBadRequest, err)
+		return
+	}
+	// 客户端断开后停止读取分片
+	file, err := h.gfs.OpenIDContext(r.Context(), id)
+	if err != nil {
+		code := http.StatusInternalServerError
+		if errors.Is(err, fs.ErrNotExist) {
+			code = http.StatusNotFound
+		}
+		serveError(w, r, code, err)
+		return
+	}
+	//goland:noinspection GoUnhandledErrorResult
+	defer file.Close()
+
+	ServeFile(w, r, file)
+}
+
+// ServeFile 将文件写入 HTTP 响应。文件实现了 io.ReadSeeker 时交由
+// http.ServeContent 处理 Range 与条件请求，否则完整输出文件内容。
+// Content-Disposition 中会带上 checksum 参数，与 transmit.Attachment 对应。
+func ServeFile(w http.ResponseWriter, r *http.Request, file File) {
+	sum := file.Checksum()
+	etag := `"` + sum + `"`
+	header := w.Header()
+	header.Set("Content-Type", file.ContentType())
+	header.Set("ETag", etag)
+	params := map[string]string{"filename": file.Name(), "checksum": sum}
+	header.Set("Content-Disposition", mime.FormatMediaType("attachment", params))
+
+	if rs, ok := file.(io.ReadSeeker); ok {
+		http.ServeContent(w, r, file.Name(), file.ModTime(), rs)
+		return
+	}
+
+	// 不支持随机读取（如：正在写入 CDN 缓存的文件），忽略 Range 完整输出
+	if matchETag(r.Header.Get("If-None-Match"), etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	header.Set("Content-Length", file.ContentLength())
+	header.Set("Last-Modified", file.ModTime().UTC().Format(http.TimeFormat))
+	w.WriteHeader(http.StatusOK)
+	if r.Method != http.MethodHead {
+		_, _ = io.Copy(w, file)
+	}
+}
+
+// matchETag 判断 If-None-Match 是否命中
+func matchETag(inm, etag string) bool {
+	for _, tag := range strings.Split(inm, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == "*" || strings.TrimPrefix(tag, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
+
+func serveError(w http.ResponseWriter, r *http.Request, code int, err error) {
+	pd := &problem.Detail{
+		Type:     "grid",
+		Title:    "文件下载错误",
+		Status:   code,
+		Detail:   err.Error(),
+		Instance: r.RequestURI,
+	}
+	_ = pd.JSON(w)
+}
@@ -0,0 +1,211 @@
+package grid
+
+import (
+	"context"
+	"database/sql"
+	"io/fs"
+	"net/url"
+	"strings"
+)
+
+// Store 分片存储后端。文件信息（grid_file、grid_blob）始终保存在 MySQL 中，
+// 以便 manager broker 之间共享同一套文件 ID，而体积较大的分片内容可以放在
+// MySQL（grid_part）、本地目录或 S3 兼容的对象存储中。
+//
+// id 为分片所属的 ID（即 grid_part.file_id），serial 为分片序号（从 0 开始）。
+type Store interface {
+	// ReadPart 读取一个分片，不存在时返回 fs.ErrNotExist。
+	ReadPart(ctx context.Context, id, serial int64) ([]byte, error)
+
+	// ReadParts 从 first 开始按序号升序读取连续的分片，读到 last 或者遇到
+	// 不存在的分片时停止，last 可以为 math.MaxInt64 代表读到最后一个分片。
+	ReadParts(ctx context.Context, id, first, last int64, fn func(serial int64, data []byte) error) error
+
+	// WritePart 写入一个分片，已存在时覆盖。
+	WritePart(ctx context.Context, id, serial int64, data []byte) error
+
+	// Serials 查询已存在的分片序号（升序）。
+	Serials(ctx context.Context, id int64) ([]int64, error)
+
+	// DeleteParts 删除 id 的全部分片。
+	DeleteParts(ctx context.Context, id int64) error
+
+	// IDs 查询存储中所有的分片所属 ID，用于清理孤立的分片。
+	IDs(ctx context.Context) ([]int64, error)
+}
+
+// OpenStore 通过地址创建分片存储，支持以下格式：
+//
+//	mysql                                              grid_part 表（使用 db）
+//	dir:///var/lib/grid                                本地目录
+//	s3://bucket/prefix?endpoint=http://127.0.0.1:9000&region=us-east-1&access_key=xxx&secret_key=xxx
+func OpenStore(db *sql.DB, addr string) (Store, error) {
+	if addr == "mysql" {
+		if db == nil {
+			return nil, fs.ErrInvalid
+		}
+		return NewMySQLStore(db), nil
+	}
+
+	u, err := url.Parse(addr)
+	if err != nil {
+		return nil, err
+	}
+	switch u.Scheme {
+	case "mysql":
+		if db == nil {
+			return nil, fs.ErrInvalid
+		}
+		return NewMySQLStore(db), nil
+	case "dir", "file":
+		dir := u.Path
+		if u.Host != "" { // dir://relative/path
+			dir = u.Host + dir
+		}
+		return NewDirStore(dir)
+	case "s3":
+		query := u.Query()
+		cfg := S3Config{
+			Endpoint:  query.Get("endpoint"),
+			Region:    query.Get("region"),
+			Bucket:    u.Host,
+			Prefix:    strings.TrimPrefix(u.Path, "/"),
+			AccessKey: query.Get("access_key"),
+			SecretKey: query.Get("secret_key"),
+		}
+		return NewS3Store(cfg)
+	}
+
+	return nil, fs.ErrInvalid
+}
+
+// NewMySQLStore 分片保存在 grid_part 表中，即原有的存储方式。
+func NewMySQLStore(db *sql.DB) Store {
+	return &mysqlStore{db: db}
+}
+
+type mysqlStore struct {
+	db *sql.DB
+}
+
+func (ms *mysqlStore) ReadPart(ctx context.Context, id, serial int64) ([]byte, error) {
+	queryPart := "SELECT `data` FROM grid_part WHERE file_id = ? AND `serial` = ?"
+	var data []byte
+	if err := ms.db.QueryRowContext(ctx, queryPart, id, serial).Scan(&data); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fs.ErrNotExist
+		}
+		return nil, err
+	}
+
+	return data, nil
+}
+
+func (ms *mysqlStore) ReadParts(ctx context.Context, id, first, last int64, fn func(int64, []byte) error) error {
+	querySQL := "SELECT `serial`, `data` FROM grid_part WHERE file_id = ? AND `serial` BETWEEN ? AND ? ORDER BY `serial`"
+	rows, err := ms.db.QueryContext(ctx, querySQL, id, first, last)
+	if err != nil {
+		return err
+	}
+	//goland:noinspection GoUnhandledErrorResult
+	defer rows.Close()
+
+	var serial int64
+	next := first
+	for rows.Next() {
+		var data []byte
+		if err = rows.Scan(&serial, &data); err != nil {
+			return err
+		}
+		if serial != next { // 分片不连续
+			return nil
+		}
+		next++
+		if err = fn(serial, data); err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
+
+func (ms *mysqlStore) WritePart(ctx context.Context, id, serial int64, data []byte) error {
+	insertPart := "INSERT INTO grid_part (file_id, `serial`, `data`) VALUE (?, ?, ?) " +
+		"ON DUPLICATE KEY UPDATE `data` = VALUES(`data`)"
+	_, err := ms.db.ExecContext(ctx, insertPart, id, serial, data)
+
+	return err
+}
+
+func (ms *mysqlStore) Serials(ctx context.Context, id int64) ([]int64, error) {
+	querySQL := "SELECT `serial` FROM grid_part WHERE file_id = ? ORDER BY `serial`"
+	rows, err := ms.db.QueryContext(ctx, querySQL, id)
+	if err != nil {
+		return nil, err
+	}
+	//goland:noinspection GoUnhandledErrorResult
+	defer rows.Close()
+
+	serials := make([]int64, 0, 8)
+	var serial int64
+	for rows.Next() {
+		if err = rows.Scan(&serial); err != nil {
+			return nil, err
+		}
+		serials = append(serials, serial)
+	}
+
+	return serials, rows.Err()
+}
+
+func (ms *mysqlStore) IDs(ctx context.Context) ([]int64, error) {
+	querySQL := "SELECT DISTINCT file_id FROM grid_part"
+	rows, err := ms.db.QueryContext(ctx, querySQL)
+	if err != nil {
+		return nil, err
+	}
+	//goland:noinspection GoUnhandledErrorResult
+	defer rows.Close()
+
+	ids := make([]int64, 0, 64)
+	var id int64
+	for rows.Next() {
+		if err = rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+
+	return ids, rows.Err()
+}
+
+func (ms *mysqlStore) DeleteParts(ctx context.Context, id int64) error {
+	deletePart := "DELETE FROM grid_part WHERE file_id = ?"
+	_, err := ms.db.ExecContext(ctx, deletePart, id)
+
+	return err
+}
+
+// readParts 逐个读取连续的分片，遇到不存在的分片时停止，用于不支持范围查询
+// 的存储后端。
+func readParts(ctx context.Context, read func(context.Context, int64, int64) ([]byte, error),
+	id, first, last int64, fn func(int64, []byte) error,
+) error {
+	for serial := first; serial <= last && serial >= 0; serial++ {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		data, err := read(ctx, id, serial)
+		if err == fs.ErrNotExist {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err = fn(serial, data); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
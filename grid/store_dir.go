@@ -0,0 +1,125 @@
+package grid
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// NewDirStore 分片保存在本地目录中，目录结构为 {dir}/{id}/{serial}.part，
+// 适用于单节点部署或者 dir 为共享存储（如：NFS）的场景。
+func NewDirStore(dir string) (Store, error) {
+	if dir == "" {
+		return nil, fs.ErrInvalid
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+
+	return &dirStore{dir: dir}, nil
+}
+
+type dirStore struct {
+	dir string
+}
+
+func (ds *dirStore) ReadPart(_ context.Context, id, serial int64) ([]byte, error) {
+	data, err := os.ReadFile(ds.partPath(id, serial))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, fs.ErrNotExist
+	}
+
+	return data, err
+}
+
+func (ds *dirStore) ReadParts(ctx context.Context, id, first, last int64, fn func(int64, []byte) error) error {
+	return readParts(ctx, ds.ReadPart, id, first, last, fn)
+}
+
+func (ds *dirStore) WritePart(_ context.Context, id, serial int64, data []byte) error {
+	dir := ds.partDir(id)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+
+	// 先写临时文件再重命名，避免读到写了一半的分片
+	tmp, err := os.CreateTemp(dir, ".tmp-*")
+	if err != nil {
+		return err
+	}
+	name := tmp.Name()
+	if _, err = tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		_ = os.Remove(name)
+		return err
+	}
+	if err = tmp.Close(); err != nil {
+		_ = os.Remove(name)
+		return err
+	}
+	if err = os.Rename(name, ds.partPath(id, serial)); err != nil {
+		_ = os.Remove(name)
+	}
+
+	return err
+}
+
+func (ds *dirStore) Serials(_ context.Context, id int64) ([]int64, error) {
+	entries, err := os.ReadDir(ds.partDir(id))
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return []int64{}, nil
+		}
+		return nil, err
+	}
+
+	serials := make([]int64, 0, len(entries))
+	for _, ent := range entries {
+		name, ok := strings.CutSuffix(ent.Name(), ".part")
+		if !ok || ent.IsDir() {
+			continue
+		}
+		if serial, err := strconv.ParseInt(name, 10, 64); err == nil {
+			serials = append(serials, serial)
+		}
+	}
+	sort.Slice(serials, func(i, j int) bool { return serials[i] < serials[j] })
+
+	return serials, nil
+}
+
+func (ds *dirStore) DeleteParts(_ context.Context, id int64) error {
+	return os.RemoveAll(ds.partDir(id))
+}
+
+func (ds *dirStore) IDs(context.Context) ([]int64, error) {
+	entries, err := os.ReadDir(ds.dir)
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make([]int64, 0, len(entries))
+	for _, ent := range entries {
+		if !ent.IsDir() {
+			continue
+		}
+		if id, err := strconv.ParseInt(ent.Name(), 10, 64); err == nil {
+			ids = append(ids, id)
+		}
+	}
+
+	return ids, nil
+}
+
+func (ds *dirStore) partDir(id int64) string {
+	return filepath.Join(ds.dir, strconv.FormatInt(id, 10))
+}
+
+func (ds *dirStore) partPath(id, serial int64) string {
+	return filepath.Join(ds.partDir(id), strconv.FormatInt(serial, 10)+".part")
+}
@@ -0,0 +1,349 @@
+package grid
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// S3Config S3 兼容对象存储的连接参数（AWS S3、MinIO 等）
+type S3Config struct {
+	Endpoint  string       // 服务地址，如：http://127.0.0.1:9000，为空时使用 AWS S3 的地址
+	Region    string       // 区域，默认 us-east-1
+	Bucket    string       // 存储桶，需要提前创建好
+	Prefix    string       // 对象名前缀，可以为空
+	AccessKey string       // Access Key
+	SecretKey string       // Secret Key
+	Client    *http.Client // 为空时使用 http.DefaultClient
+}
+
+// NewS3Store 分片保存在 S3 兼容的对象存储中，对象名为 {prefix}/{id}/{serial}，
+// 使用 path-style 访问并以 AWS Signature V4 签名，不依赖 AWS SDK。
+func NewS3Store(cfg S3Config) (Store, error) {
+	if cfg.Bucket == "" {
+		return nil, fs.ErrInvalid
+	}
+	if cfg.Region == "" {
+		cfg.Region = "us-east-1"
+	}
+	if cfg.Endpoint == "" {
+		cfg.Endpoint = "https://s3." + cfg.Region + ".amazonaws.com"
+	}
+	endpoint, err := url.Parse(strings.TrimSuffix(cfg.Endpoint, "/"))
+	if err != nil {
+		return nil, err
+	}
+	if cfg.Client == nil {
+		cfg.Client = http.DefaultClient
+	}
+	cfg.Prefix = strings.Trim(cfg.Prefix, "/")
+
+	return &s3Store{cfg: cfg, endpoint: endpoint}, nil
+}
+
+// S3Error 对象存储返回的错误
+type S3Error struct {
+	StatusCode int    `xml:"-"`
+	Code       string `xml:"Code"`
+	Message    string `xml:"Message"`
+}
+
+func (e *S3Error) Error() string {
+	msg := "grid: s3 " + strconv.Itoa(e.StatusCode)
+	if e.Code != "" {
+		msg += " " + e.Code
+	}
+	if e.Message != "" {
+		msg += ": " + e.Message
+	}
+	return msg
+}
+
+type s3Store struct {
+	cfg      S3Config
+	endpoint *url.URL
+}
+
+func (ss *s3Store) ReadPart(ctx context.Context, id, serial int64) ([]byte, error) {
+	res, err := ss.do(ctx, http.MethodGet, ss.key(id, serial), nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	//goland:noinspection GoUnhandledErrorResult
+	defer res.Body.Close()
+
+	return io.ReadAll(res.Body)
+}
+
+// ReadParts 逐个 GET 分片，不需要 LIST，不存在的分片（404）代表读完。
+func (ss *s3Store) ReadParts(ctx context.Context, id, first, last int64, fn func(int64, []byte) error) error {
+	return readParts(ctx, ss.ReadPart, id, first, last, fn)
+}
+
+func (ss *s3Store) WritePart(ctx context.Context, id, serial int64, data []byte) error {
+	res, err := ss.do(ctx, http.MethodPut, ss.key(id, serial), nil, data)
+	if err != nil {
+		return err
+	}
+
+	return res.Body.Close()
+}
+
+func (ss *s3Store) Serials(ctx context.Context, id int64) ([]int64, error) {
+	keys, _, err := ss.list(ctx, ss.key(id, -1), "")
+	if err != nil {
+		return nil, err
+	}
+
+	serials := make([]int64, 0, len(keys))
+	for _, key := range keys {
+		if serial, err := strconv.ParseInt(path.Base(key), 10, 64); err == nil {
+			serials = append(serials, serial)
+		}
+	}
+	sort.Slice(serials, func(i, j int) bool { return serials[i] < serials[j] })
+
+	return serials, nil
+}
+
+func (ss *s3Store) DeleteParts(ctx context.Context, id int64) error {
+	keys, _, err := ss.list(ctx, ss.key(id, -1), "")
+	if err != nil {
+		return err
+	}
+	for _, key := range keys {
+		res, err := ss.do(ctx, http.MethodDelete, key, nil, nil)
+		if err != nil {
+			if err == fs.ErrNotExist {
+				continue
+			}
+			return err
+		}
+		_ = res.Body.Close()
+	}
+
+	return nil
+}
+
+func (ss *s3Store) IDs(ctx context.Context) ([]int64, error) {
+	var prefix string
+	if ss.cfg.Prefix != "" {
+		prefix = ss.cfg.Prefix + "/"
+	}
+	_, dirs, err := ss.list(ctx, prefix, "/")
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make([]int64, 0, len(dirs))
+	for _, dir := range dirs {
+		name := strings.TrimSuffix(strings.TrimPrefix(dir, prefix), "/")
+		if id, err := strconv.ParseInt(name, 10, 64); err == nil {
+			ids = append(ids, id)
+		}
+	}
+
+	return ids, nil
+}
+
+// key 分片的对象名，serial 小于 0 时返回 id 的目录前缀。
+func (ss *s3Store) key(id, serial int64) string {
+	key := strconv.FormatInt(id, 10) + "/"
+	if serial >= 0 {
+		key += strconv.FormatInt(serial, 10)
+	}
+	if ss.cfg.Prefix != "" {
+		key = ss.cfg.Prefix + "/" + key
+	}
+
+	return key
+}
+
+// list 通过 ListObjectsV2 列出前缀下的所有对象名，delimiter 不为空时
+// 同时返回按 delimiter 折叠的公共前缀（即子目录）。
+func (ss *s3Store) list(ctx context.Context, prefix, delimiter string) ([]string, []string, error) {
+	var keys, dirs []string
+	var token string
+	for {
+		query := url.Values{"list-type": {"2"}, "prefix": {prefix}}
+		if delimiter != "" {
+			query.Set("delimiter", delimiter)
+		}
+		if token != "" {
+			query.Set("continuation-token", token)
+		}
+		res, err := ss.do(ctx, http.MethodGet, "", query, nil)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		var ret struct {
+			Contents []struct {
+				Key string `xml:"Key"`
+			} `xml:"Contents"`
+			CommonPrefixes []struct {
+				Prefix string `xml:"Prefix"`
+			} `xml:"CommonPrefixes"`
+			IsTruncated           bool   `xml:"IsTruncated"`
+			NextContinuationToken string `xml:"NextContinuationToken"`
+		}
+		err = xml.NewDecoder(res.Body).Decode(&ret)
+		_ = res.Body.Close()
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, c := range ret.Contents {
+			keys = append(keys, c.Key)
+		}
+		for _, c := range ret.CommonPrefixes {
+			dirs = append(dirs, c.Prefix)
+		}
+		if !ret.IsTruncated || ret.NextContinuationToken == "" {
+			return keys, dirs, nil
+		}
+		token = ret.NextContinuationToken
+	}
+}
+
+// do 发送签名后的请求，404 返回 fs.ErrNotExist，其它非 2xx 状态码返回 *S3Error。
+func (ss *s3Store) do(ctx context.Context, method, key string, query url.Values, body []byte) (*http.Response, error) {
+	u := *ss.endpoint
+	u.Path = strings.TrimSuffix(u.Path, "/") + "/" + ss.cfg.Bucket
+	if key != "" {
+		u.Path += "/" + key
+	}
+	u.RawPath = uriEncode(u.Path, false)
+	u.RawQuery = canonicalQuery(query)
+
+	var rd io.Reader
+	if body != nil {
+		rd = bytes.NewReader(body)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), rd)
+	if err != nil {
+		return nil, err
+	}
+	ss.sign(req, body, time.Now().UTC())
+
+	res, err := ss.cfg.Client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	code := res.StatusCode
+	if code >= 200 && code < 300 {
+		return res, nil
+	}
+	//goland:noinspection GoUnhandledErrorResult
+	defer res.Body.Close()
+
+	if code == http.StatusNotFound && key != "" {
+		return nil, fs.ErrNotExist
+	}
+	se := &S3Error{StatusCode: code}
+	raw, _ := io.ReadAll(io.LimitReader(res.Body, 4096))
+	_ = xml.Unmarshal(raw, se)
+
+	return nil, se
+}
+
+// sign AWS Signature Version 4
+func (ss *s3Store) sign(req *http.Request, body []byte, now time.Time) {
+	payload := sha256.Sum256(body)
+	payloadHash := hex.EncodeToString(payload[:])
+	amzDate := now.Format("20060102T150405Z")
+	day := now.Format("20060102")
+
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	if body != nil {
+		req.ContentLength = int64(len(body))
+	}
+
+	signedHeaders := "host;x-amz-content-sha256;x-amz-date"
+	canonical := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		req.URL.RawQuery,
+		"host:" + req.URL.Host + "\n" +
+			"x-amz-content-sha256:" + payloadHash + "\n" +
+			"x-amz-date:" + amzDate + "\n",
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := day + "/" + ss.cfg.Region + "/s3/aws4_request"
+	sum := sha256.Sum256([]byte(canonical))
+	toSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(sum[:])
+
+	key := hmacSHA256([]byte("AWS4"+ss.cfg.SecretKey), day)
+	key = hmacSHA256(key, ss.cfg.Region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, toSign))
+
+	req.Header.Set("Authorization", "AWS4-HMAC-SHA256 Credential="+ss.cfg.AccessKey+"/"+scope+
+		", SignedHeaders="+signedHeaders+", Signature="+signature)
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+// canonicalQuery 按照 SigV4 的要求对查询参数排序并编码
+func canonicalQuery(query url.Values) string {
+	if len(query) == 0 {
+		return ""
+	}
+	keys := make([]string, 0, len(query))
+	for k := range query {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	pairs := make([]string, 0, len(keys))
+	for _, k := range keys {
+		vals := query[k]
+		sort.Strings(vals)
+		for _, v := range vals {
+			pairs = append(pairs, uriEncode(k, true)+"="+uriEncode(v, true))
+		}
+	}
+
+	return strings.Join(pairs, "&")
+}
+
+// uriEncode 除 A-Z a-z 0-9 - _ . ~ 外全部编码，slash 代表是否编码 '/'
+func uriEncode(s string, slash bool) string {
+	const hexChars = "0123456789ABCDEF"
+	var sb strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9',
+			c == '-', c == '_', c == '.', c == '~':
+			sb.WriteByte(c)
+		case c == '/' && !slash:
+			sb.WriteByte(c)
+		default:
+			sb.WriteByte('%')
+			sb.WriteByte(hexChars[c>>4])
+			sb.WriteByte(hexChars[c&15])
+		}
+	}
+
+	return sb.String()
+}
@@ -0,0 +1,288 @@
+package grid
+
+import (
+	"context"
+	"crypto/sha1"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"io/fs"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrChecksum 校验码不一致
+	ErrChecksum = errors.New("grid: checksum mismatch")
+
+	// ErrPartSerial 分片序号超出范围
+	ErrPartSerial = errors.New("grid: part serial out of range")
+
+	// ErrPartSize 分片大小与会话约定的不一致
+	ErrPartSize = errors.New("grid: part size mismatch")
+
+	// ErrUploadDone 上传会话已经提交
+	ErrUploadDone = errors.New("grid: upload already committed")
+)
+
+// IncompleteError 提交时仍有分片未上传
+type IncompleteError struct {
+	Missing []int64 // 缺失的分片序号
+}
+
+func (e *IncompleteError) Error() string {
+	const max = 10
+	nums := make([]string, 0, max)
+	for i, n := range e.Missing {
+		if i >= max {
+			nums = append(nums, "...")
+			break
+		}
+		nums = append(nums, strconv.FormatInt(n, 10))
+	}
+
+	return "grid: upload incomplete, missing parts: " + strings.Join(nums, ",")
+}
+
+// Uploader 可断点续传的分片上传：先创建会话，然后按任意顺序上传（或重传）
+// 每个分片，每个分片都是独立的短事务，客户端断开后可以查询缺失的分片继续
+// 上传，全部上传完毕后提交，提交时校验整个文件的 SHA-1 并将 grid_file.done
+// 置为 1，在此之前文件对 OpenID 不可见。
+type Uploader interface {
+	// CreateUpload 创建上传会话，size 为文件总大小，checksum 为文件 SHA-1
+	// （为空代表不校验）。
+	CreateUpload(name string, size int64, checksum string) (*UploadSession, error)
+
+	// UploadPart 上传第 serial 个分片（从 0 开始），checksum 为分片的 SHA-1
+	// （为空代表不校验），重复上传同一个分片会覆盖。
+	UploadPart(id, serial int64, data []byte, checksum string) error
+
+	// MissingParts 查询还未上传的分片序号
+	MissingParts(id int64) ([]int64, error)
+
+	// CommitUpload 提交上传会话，分片缺失时返回 *IncompleteError。
+	CommitUpload(id int64) (File, error)
+
+	// AbortUpload 放弃上传会话并删除已上传的分片
+	AbortUpload(id int64) error
+}
+
+// UploadSession 上传会话
+type UploadSession struct {
+	ID        int64     `json:"id,string"`  // 文件 ID，也是会话 ID
+	Name      string    `json:"name"`       // 文件名
+	Size      int64     `json:"size"`       // 文件大小
+	Checksum  string    `json:"checksum"`   // 文件 SHA-1
+	Burst     int       `json:"burst"`      // 分片大小，除最后一个分片外每个分片都必须是该大小
+	Parts     int64     `json:"parts"`      // 分片总数
+	CreatedAt time.Time `json:"created_at"` // 会话创建时间
+
+	opt WriteOptions // 分片编码方式
+}
+
+// PartSize 第 serial 个分片应有的大小
+func (us *UploadSession) PartSize(serial int64) int {
+	if serial < 0 || serial >= us.Parts {
+		return -1
+	}
+	if serial == us.Parts-1 {
+		return int(us.Size - serial*int64(us.Burst))
+	}
+	return us.Burst
+}
+
+func newUploadSession(id int64, name string, size int64, sum string, burst int, createdAt time.Time) *UploadSession {
+	parts := (size + int64(burst) - 1) / int64(burst)
+	return &UploadSession{
+		ID:        id,
+		Name:      name,
+		Size:      size,
+		Checksum:  sum,
+		Burst:     burst,
+		Parts:     parts,
+		CreatedAt: createdAt,
+	}
+}
+
+func (gfs *gridFS) CreateUpload(name string, size int64, checksum string) (*UploadSession, error) {
+	if size < 0 {
+		return nil, fs.ErrInvalid
+	}
+	opt := gfs.wopt
+	if _, err := newPartCodec(opt.Codec, opt.KeyID, gfs.keys); err != nil {
+		return nil, err
+	}
+	checksum = strings.ToLower(checksum)
+	burst := gfs.burst
+	createdAt := time.Now()
+	insertFile := "INSERT INTO grid_file(`name`, size, sha1, burst, codec, key_id, fixed, created_at) VALUE (?, ?, ?, ?, ?, ?, ?, ?)"
+	ret, err := gfs.db.Exec(insertFile, name, size, checksum, burst, opt.Codec, opt.KeyID, true, createdAt)
+	if err != nil {
+		return nil, err
+	}
+	fileID, err := ret.LastInsertId()
+	if err != nil {
+		return nil, err
+	}
+
+	sess := newUploadSession(fileID, name, size, checksum, burst, createdAt)
+	sess.opt = opt
+
+	return sess, nil
+}
+
+func (gfs *gridFS) UploadPart(id, serial int64, data []byte, checksum string) error {
+	sess, err := gfs.loadSession(id)
+	if err != nil {
+		return err
+	}
+	want := sess.PartSize(serial)
+	if want < 0 {
+		return ErrPartSerial
+	}
+	if len(data) != want {
+		return ErrPartSize
+	}
+	if checksum != "" {
+		sum := sha1.Sum(data)
+		if !strings.EqualFold(hex.EncodeToString(sum[:]), checksum) {
+			return ErrChecksum
+		}
+	}
+
+	if data, err = gfs.partCodec(sess.opt.Codec, sess.opt.KeyID).encode(id, serial, data); err != nil {
+		return err
+	}
+
+	return gfs.store.WritePart(context.Background(), id, serial, data)
+}
+
+func (gfs *gridFS) MissingParts(id int64) ([]int64, error) {
+	sess, err := gfs.loadSession(id)
+	if err != nil {
+		return nil, err
+	}
+
+	return gfs.missingParts(sess)
+}
+
+func (gfs *gridFS) CommitUpload(id int64) (File, error) {
+	sess, err := gfs.loadSession(id)
+	if err != nil {
+		return nil, err
+	}
+	missing, err := gfs.missingParts(sess)
+	if err != nil {
+		return nil, err
+	}
+	if len(missing) != 0 {
+		return nil, &IncompleteError{Missing: missing}
+	}
+
+	// 按顺序读取所有分片计算 SHA-1
+	ctx := context.Background()
+	checksum := sha1.New()
+	var filesize, next int64
+	pc := gfs.partCodec(sess.opt.Codec, sess.opt.KeyID)
+	err = gfs.store.ReadParts(ctx, id, 0, sess.Parts-1, func(serial int64, data []byte) error {
+		if serial != next {
+			return &IncompleteError{Missing: []int64{next}}
+		}
+		next++
+		data, err := pc.decode(id, serial, data)
+		if err != nil {
+			return err
+		}
+		filesize += int64(len(data))
+		checksum.Write(data)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if filesize != sess.Size {
+		return nil, ErrPartSize
+	}
+	sum := hex.EncodeToString(checksum.Sum(nil))
+	if sess.Checksum != "" && sum != sess.Checksum {
+		return nil, ErrChecksum
+	}
+
+	// 已经存在相同内容时复用已有的分片
+	blob, err := gfs.publish(ctx, id, filesize, sum, sess.Burst, sess.opt)
+	if err != nil {
+		return nil, err
+	}
+
+	fl := &file{
+		id:        id,
+		filename:  sess.Name,
+		filesize:  filesize,
+		sha1:      sum,
+		burst:     sess.Burst,
+		done:      true,
+		blobID:    blob.id,
+		codec:     sess.opt.Codec,
+		keyID:     sess.opt.KeyID,
+		fixed:     blob.fixed,
+		createdAt: sess.CreatedAt,
+		updatedAt: blob.updatedAt,
+		store:     gfs.store,
+		pc:        pc,
+		prefetch:  gfs.prefetch,
+	}
+
+	return fl, nil
+}
+
+func (gfs *gridFS) AbortUpload(id int64) error {
+	if _, err := gfs.loadSession(id); err != nil {
+		return err
+	}
+
+	return gfs.Remove(id)
+}
+
+// loadSession 查询未提交的上传会话
+func (gfs *gridFS) loadSession(id int64) (*UploadSession, error) {
+	rawSQL := "SELECT `name`, size, sha1, burst, done, codec, key_id, created_at FROM grid_file WHERE id = ?"
+	var name, sum string
+	var size int64
+	var burst int
+	var done bool
+	var opt WriteOptions
+	var createdAt time.Time
+	if err := gfs.db.QueryRow(rawSQL, id).
+		Scan(&name, &size, &sum, &burst, &done, &opt.Codec, &opt.KeyID, &createdAt); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fs.ErrNotExist
+		}
+		return nil, err
+	}
+	if done {
+		return nil, ErrUploadDone
+	}
+	if burst <= 0 {
+		return nil, fs.ErrInvalid
+	}
+
+	sess := newUploadSession(id, name, size, sum, burst, createdAt)
+	sess.opt = opt
+
+	return sess, nil
+}
+
+// missingParts 对比已上传的分片序号，找出缺失的分片
+func (gfs *gridFS) missingParts(sess *UploadSession) ([]int64, error) {
+	serials, err := gfs.store.Serials(context.Background(), sess.ID)
+	if err != nil {
+		return nil, err
+	}
+	missing := missingSerials(serials, sess.Parts)
+	if missing == nil {
+		missing = []int64{}
+	}
+
+	return missing, nil
+}
@@ -110,6 +110,12 @@ func (c Client) fetch(ctx context.Context, method string, addr *url.URL, body io
 	}
 
 	req := c.NewRequest(ctx, method, addr, body, header)
+
+	return c.send(req)
+}
+
+// send 发送构造好的请求，并处理响应的压缩编码与错误状态码
+func (c Client) send(req *http.Request) (*http.Response, error) {
 	req.Header.Set("Accept-Encoding", "gzip, deflate")
 	res, err := c.cli.Do(req)
 	if err != nil {
@@ -0,0 +1,205 @@
+package httpx
+
+import (
+	"context"
+	"io"
+	"mime/multipart"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+)
+
+// Progress 上传进度回调，written 为已发送的字节数，total 为总字节数（未知时为 -1）
+type Progress func(written, total int64)
+
+// Multipart multipart/form-data 请求体
+type Multipart struct {
+	Fields map[string]string // 普通表单字段
+	Files  []*MultipartFile  // 文件字段
+}
+
+// MultipartFile multipart/form-data 中的文件
+type MultipartFile struct {
+	Field    string    // 表单字段名
+	Filename string    // 文件名
+	Reader   io.Reader // 文件内容
+	Size     int64     // 文件大小，小于 0 代表未知
+}
+
+// Upload 以原始流的方式上传数据，size 为数据长度（小于 0 代表未知，将使用
+// chunked 编码发送），数据不会在内存中缓存。
+func (c Client) Upload(ctx context.Context, method string, addr *url.URL, body io.Reader, size int64, header http.Header, fn Progress) (*http.Response, error) {
+	if addr == nil {
+		return nil, &net.AddrError{Err: "target url is nil"}
+	}
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	pr := &progressReader{ctx: ctx, rd: body, total: size, fn: fn}
+	req := c.newRequest(ctx, method, addr, pr, header)
+	if size >= 0 {
+		req.ContentLength = size
+		if size == 0 {
+			req.Body = http.NoBody
+		}
+	} else {
+		req.ContentLength = -1
+	}
+	if req.Header.Get("Content-Type") == "" {
+		req.Header.Set("Content-Type", "application/octet-stream")
+	}
+
+	return c.send(req)
+}
+
+// UploadMultipart 以 multipart/form-data 的方式上传，请求体边生成边发送，
+// 当所有文件的 Size 都已知时会计算出准确的 Content-Length。
+func (c Client) UploadMultipart(ctx context.Context, method string, addr *url.URL, mp *Multipart, header http.Header, fn Progress) (*http.Response, error) {
+	if mp == nil {
+		mp = new(Multipart)
+	}
+
+	pr, pw := io.Pipe()
+	mw := multipart.NewWriter(pw)
+	size := mp.contentLength(mw.Boundary())
+
+	go func() {
+		_ = pw.CloseWithError(mp.writeTo(mw))
+	}()
+
+	if header == nil {
+		header = make(http.Header, 2)
+	}
+	header.Set("Content-Type", mw.FormDataContentType())
+	res, err := c.Upload(ctx, method, addr, pr, size, header, fn)
+	_ = pr.Close() // 让写入协程退出
+
+	return res, err
+}
+
+// UploadFile 上传本地文件，field 为空时以原始流方式上传，否则以
+// multipart/form-data 方式上传。
+func (c Client) UploadFile(ctx context.Context, method string, addr *url.URL, field, path string, fields map[string]string, header http.Header, fn Progress) (*http.Response, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	//goland:noinspection GoUnhandledErrorResult
+	defer file.Close()
+
+	stat, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+	size := stat.Size()
+	if field == "" {
+		return c.Upload(ctx, method, addr, file, size, header, fn)
+	}
+
+	mp := &Multipart{
+		Fields: fields,
+		Files: []*MultipartFile{
+			{Field: field, Filename: filepath.Base(path), Reader: file, Size: size},
+		},
+	}
+
+	return c.UploadMultipart(ctx, method, addr, mp, header, fn)
+}
+
+// writeTo 将表单内容写入 multipart.Writer
+func (mp *Multipart) writeTo(mw *multipart.Writer) error {
+	for k, v := range mp.Fields {
+		if err := mw.WriteField(k, v); err != nil {
+			return err
+		}
+	}
+	for _, f := range mp.Files {
+		w, err := mw.CreateFormFile(f.Field, f.Filename)
+		if err != nil {
+			return err
+		}
+		if f.Reader == nil {
+			continue
+		}
+		if _, err = io.Copy(w, f.Reader); err != nil {
+			return err
+		}
+	}
+
+	return mw.Close()
+}
+
+// contentLength 计算请求体的总长度，有文件大小未知时返回 -1。
+// 使用相同的 boundary 生成一遍不含文件内容的报文，再加上文件大小即可。
+func (mp *Multipart) contentLength(boundary string) int64 {
+	var total int64
+	empty := make([]*MultipartFile, 0, len(mp.Files))
+	for _, f := range mp.Files {
+		if f.Size < 0 || f.Reader == nil && f.Size != 0 {
+			return -1
+		}
+		total += f.Size
+		empty = append(empty, &MultipartFile{Field: f.Field, Filename: f.Filename})
+	}
+
+	cw := new(countWriter)
+	mw := multipart.NewWriter(cw)
+	if err := mw.SetBoundary(boundary); err != nil {
+		return -1
+	}
+	frame := &Multipart{Fields: mp.Fields, Files: empty}
+	if err := frame.writeTo(mw); err != nil {
+		return -1
+	}
+
+	return total + cw.n
+}
+
+type countWriter struct {
+	n int64
+}
+
+func (cw *countWriter) Write(p []byte) (int, error) {
+	n := len(p)
+	cw.n += int64(n)
+	return n, nil
+}
+
+// progressReader 统计读取进度并响应 context 取消
+type progressReader struct {
+	ctx     context.Context
+	rd      io.Reader
+	total   int64
+	written atomic.Int64
+	fn      Progress
+}
+
+func (pr *progressReader) Read(p []byte) (int, error) {
+	if err := pr.ctx.Err(); err != nil {
+		return 0, err
+	}
+	if pr.rd == nil {
+		return 0, io.EOF
+	}
+
+	n, err := pr.rd.Read(p)
+	if n > 0 {
+		written := pr.written.Add(int64(n))
+		if pr.fn != nil {
+			pr.fn(written, pr.total)
+		}
+	}
+
+	return n, err
+}
+
+func (pr *progressReader) Close() error {
+	if rc, ok := pr.rd.(io.Closer); ok {
+		return rc.Close()
+	}
+	return nil
+}
@@ -4,28 +4,28 @@ import "time"
 
 // Risk 节点风险事件
 type Risk struct {
-	ID       int64  `json:"id,string"        gorm:"column:id;primaryKey"` // 数据ID
-	MinionID int64  `json:"minion_id,string" gorm:"column:minion_id"`     // 节点ID
-	Inet     string `json:"inet"             gorm:"column:inet"`          // 节点 IPv4
+	ID       int64  `json:"id,string"        gorm:"column:id;primaryKey" dynsql:"name:ID;filter;order"`           // 数据ID
+	MinionID int64  `json:"minion_id,string" gorm:"column:minion_id"     dynsql:"name:节点ID"`                      // 节点ID
+	Inet     string `json:"inet"             gorm:"column:inet"          dynsql:"name:节点IP;type:ip;filter;group"` // 节点 IPv4
 
 	// RiskType 风险类型
 	// ["暴力破解", "病毒事件", "弱口令", "数据爬虫", "蜜罐应用", "web 攻击", "监控事件", "登录事件"]
-	RiskType   string     `json:"risk_type"   gorm:"column:risk_type"`
-	Level      RiskLevel  `json:"level"       gorm:"column:level"`       // 风险级别
-	Payload    string     `json:"payload"     gorm:"column:payload"`     // 攻击载荷
-	Subject    string     `json:"subject"     gorm:"column:subject"`     // 风险事件主题
-	LocalIP    string     `json:"local_ip"    gorm:"column:local_ip"`    // 本地 IP
-	LocalPort  int        `json:"local_port"  gorm:"column:local_port"`  // 本地端口
-	RemoteIP   string     `json:"remote_ip"   gorm:"column:remote_ip"`   // 远程 IP
-	RemotePort int        `json:"remote_port" gorm:"column:remote_port"` // 远程端口
-	FromCode   string     `json:"from_code"   gorm:"column:from_code"`   // 来源模块
-	Region     string     `json:"region"      gorm:"column:region"`      // IP 归属地
-	Reference  string     `json:"reference"   gorm:"column:reference"`   // 参考引用
-	SendAlert  bool       `json:"send_alert"  gorm:"column:send_alert"`  // 是否发送告警
-	Secret     string     `json:"-"           gorm:"column:secret"`      // 查询密文
-	Status     RiskStatus `json:"status"      gorm:"column:status"`      // 状态: 1-未处理 2-已处理 3-忽略
-	OccurAt    time.Time  `json:"occur_at"    gorm:"column:occur_at"`    // 风险产生的时间
-	CreatedAt  time.Time  `json:"created_at"  gorm:"column:created_at"`  // 入库保存时间
+	RiskType   string     `json:"risk_type"   gorm:"column:risk_type"   dynsql:"name:风险类型;filter;group"`
+	Level      RiskLevel  `json:"level"       gorm:"column:level"       dynsql:"name:风险级别;enums:紧急,高危,中危,低危;filter;group"`    // 风险级别
+	Payload    string     `json:"payload"     gorm:"column:payload"     dynsql:"name:攻击载荷"`                                   // 攻击载荷
+	Subject    string     `json:"subject"     gorm:"column:subject"     dynsql:"name:风险事件主题"`                                 // 风险事件主题
+	LocalIP    string     `json:"local_ip"    gorm:"column:local_ip"    dynsql:"name:本地IP;type:ip"`                           // 本地 IP
+	LocalPort  int        `json:"local_port"  gorm:"column:local_port"  dynsql:"name:本地端口"`                                   // 本地端口
+	RemoteIP   string     `json:"remote_ip"   gorm:"column:remote_ip"   dynsql:"name:远程IP;type:ip;filter;group"`              // 远程 IP
+	RemotePort int        `json:"remote_port" gorm:"column:remote_port" dynsql:"name:远程端口"`                                   // 远程端口
+	FromCode   string     `json:"from_code"   gorm:"column:from_code"   dynsql:"name:来源模块;filter;group"`                      // 来源模块
+	Region     string     `json:"region"      gorm:"column:region"      dynsql:"name:IP归属地;filter;group"`                     // IP 归属地
+	Reference  string     `json:"reference"   gorm:"column:reference"   dynsql:"name:参考引用"`                                   // 参考引用
+	SendAlert  bool       `json:"send_alert"  gorm:"column:send_alert"  dynsql:"name:是否发送告警"`                                 // 是否发送告警
+	Secret     string     `json:"-"           gorm:"column:secret"      dynsql:"-"`                                           // 查询密文
+	Status     RiskStatus `json:"status"      gorm:"column:status"      dynsql:"name:状态;enums:1=未处理,2=已处理,3=忽略;filter;group"` // 状态: 1-未处理 2-已处理 3-忽略
+	OccurAt    time.Time  `json:"occur_at"    gorm:"column:occur_at"    dynsql:"name:风险产生时间;filter;order;group"`              // 风险产生的时间
+	CreatedAt  time.Time  `json:"created_at"  gorm:"column:created_at"  dynsql:"name:入库时间;filter;order"`                      // 入库保存时间
 }
 
 // TableName implement gorm schema.Tabler
@@ -2,7 +2,8 @@ package opencond
 
 import (
 	"strconv"
-	"time"
+
+	"github.com/vela-ssoc/backend-common/dynsql"
 )
 
 var (
@@ -19,8 +20,8 @@ type datatype interface {
 	// operators 该类型的默认可用操作符
 	operators() []operator
 
-	// cast 将 string 转为实现的类型
-	cast(string) (any, error)
+	// column 生成对应类型的 dynsql 列，类型转换与枚举检查都由 dynsql 完成。
+	column(col, desc string, ops []dynsql.Operator, enums Pairs) dynsql.Column
 }
 
 // stringType string
@@ -34,8 +35,17 @@ func (*stringType) operators() []operator {
 	return []operator{OpEq, OpNe, OpIn, OpNotIn, OpLike, OpNotLike}
 }
 
-func (st *stringType) cast(str string) (any, error) {
-	return str, nil
+func (*stringType) column(col, desc string, ops []dynsql.Operator, enums Pairs) dynsql.Column {
+	cb := dynsql.StringColumn(col, desc).Operators(ops)
+	if len(enums) != 0 {
+		eb := dynsql.StringEnum()
+		for _, p := range enums {
+			eb.Set(p.Key, p.Desc)
+		}
+		cb.Enums(eb)
+	}
+
+	return cb.Build()
 }
 
 // intType int
@@ -49,11 +59,22 @@ func (*intType) operators() []operator {
 	return []operator{OpEq, OpNe, OpGt, OpLt, OpGte, OpLte, OpIn, OpNotIn}
 }
 
-func (*intType) cast(str string) (any, error) {
-	return strconv.Atoi(str)
+func (*intType) column(col, desc string, ops []dynsql.Operator, enums Pairs) dynsql.Column {
+	cb := dynsql.IntColumn(col, desc).Operators(ops)
+	if len(enums) != 0 {
+		eb := dynsql.IntEnum()
+		for _, p := range enums {
+			if n, err := strconv.Atoi(p.Key); err == nil { // 不是数字的枚举值永远不会匹配，忽略即可
+				eb.Set(n, p.Desc)
+			}
+		}
+		cb.Enums(eb)
+	}
+
+	return cb.Build()
 }
 
-// intType bool
+// boolType bool
 type boolType struct{}
 
 func (*boolType) key() string {
@@ -64,8 +85,18 @@ func (*boolType) operators() []operator {
 	return []operator{OpEq, OpNe, OpIn, OpNotIn}
 }
 
-func (bt *boolType) cast(str string) (any, error) {
-	return strconv.ParseBool(str)
+func (*boolType) column(col, desc string, ops []dynsql.Operator, enums Pairs) dynsql.Column {
+	eb := dynsql.BoolEnum()
+	for _, p := range enums {
+		switch p.Key {
+		case "true":
+			eb.True(p.Desc)
+		case "false":
+			eb.False(p.Desc)
+		}
+	}
+
+	return dynsql.BoolColumn(col, desc).Operators(ops).Enums(eb).Build()
 }
 
 // datetimeType datetime
@@ -79,8 +110,6 @@ func (*datetimeType) operators() []operator {
 	return []operator{OpEq, OpNe, OpGt, OpLt, OpGte, OpLte, OpIn, OpNotIn}
 }
 
-func (*datetimeType) cast(str string) (any, error) {
-	tm := &time.Time{}
-	err := tm.UnmarshalText([]byte(str))
-	return tm, err
+func (*datetimeType) column(col, desc string, ops []dynsql.Operator, _ Pairs) dynsql.Column {
+	return dynsql.TimeColumn(col, desc).Operators(ops).Build()
 }
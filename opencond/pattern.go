@@ -1,6 +1,6 @@
 package opencond
 
-import "fmt"
+import "github.com/vela-ssoc/backend-common/dynsql"
 
 type patternBuilder struct {
 	key       string
@@ -41,6 +41,7 @@ func (sb *patternBuilder) Build() *pattern {
 	size := len(operators)
 	ops := make(Pairs, 0, size)
 	opm := make(map[string]operator, size)
+	dops := make([]dynsql.Operator, 0, size)
 	for _, op := range operators {
 		key := op.String()
 		if _, exist := opm[key]; exist {
@@ -48,11 +49,11 @@ func (sb *patternBuilder) Build() *pattern {
 		}
 		opm[key] = op
 		ops = append(ops, &pair{Key: key, Desc: op.desc()})
+		dops = append(dops, op.operator())
 	}
 
 	// 处理 enums
-	ehm := make(map[string]*pair, 8)
-	enumKeys := make([]string, 0, 8)
+	ehm := make(map[string]struct{}, 8)
 	var enums Pairs
 	enum := len(sb.enums) > 0
 	if enum {
@@ -61,9 +62,8 @@ func (sb *patternBuilder) Build() *pattern {
 			if _, exist := ehm[key]; exist {
 				continue
 			}
-			ehm[key] = p
+			ehm[key] = struct{}{}
 			enums = append(enums, &pair{Key: key, Desc: p.Desc})
-			enumKeys = append(enumKeys, key)
 		}
 	}
 
@@ -72,57 +72,26 @@ func (sb *patternBuilder) Build() *pattern {
 	return &pattern{
 		key:       sb.key,
 		column:    sb.column,
-		desc:      sb.desc,
-		datatype:  sb.datatype,
 		operators: opm,
-		enum:      enum,
-		enumKeys:  enumKeys,
-		enums:     ehm,
+		col:       sb.datatype.column(sb.column, sb.desc, dops, enums),
 		schema:    shm,
 	}
 }
 
+// pattern 查询字段，key 是前端使用的名字，column 是数据库中的列名。
 type pattern struct {
 	key       string
 	column    string
-	desc      string
-	datatype  datatype
 	operators map[string]operator
-	enum      bool
-	enumKeys  []string
-	enums     map[string]*pair
+	col       dynsql.Column
 	schema    *schema
 }
 
-func (pt pattern) inEnums(str string) error {
-	if !pt.enum {
-		return nil
-	}
-	if _, exist := pt.enums[str]; exist {
-		return nil
-	}
-
-	return fmt.Errorf("%s必须是%v中的一个", pt.key, pt.enumKeys)
-}
-
 type Patterns []*pattern
 
 func (pts Patterns) State() State {
-	length := len(pts)
-	patterns := make(map[string]*pattern, length)
-	schemas := make([]*schema, 0, length)
-
-	for _, pt := range pts {
-		key := pt.key
-		if _, exist := patterns[key]; exist {
-			continue
-		}
-
-		patterns[key] = pt
-		schemas = append(schemas, pt.schema)
-	}
-
-	return State{patterns: patterns, schemas: schemas}
+	pt := &Pattern{fields: pts}
+	return pt.State()
 }
 
 type Pattern struct {
@@ -154,6 +123,7 @@ func (pt *Pattern) State() State {
 	fsz := len(pt.fields)
 	fieldMap := make(map[string]*pattern, fsz)
 	fields := make([]*schema, 0, fsz)
+	cols := make([]dynsql.Column, 0, fsz)
 
 	for _, p := range pt.fields {
 		key := p.key
@@ -163,11 +133,13 @@ func (pt *Pattern) State() State {
 
 		fieldMap[key] = p
 		fields = append(fields, p.schema)
+		cols = append(cols, p.col)
 	}
 
 	gsz := len(pt.groups)
 	groupMap := make(map[string]*pair, gsz)
 	groups := make([]*pair, 0, gsz)
+	gcols := make([]dynsql.Column, 0, gsz)
 	for _, p := range pt.groups {
 		key := p.Key
 		if _, exist := groupMap[key]; exist {
@@ -176,13 +148,23 @@ func (pt *Pattern) State() State {
 
 		groupMap[key] = p
 		groups = append(groups, p)
+		gcols = append(gcols, dynsql.StringColumn(key, p.Desc).Build())
 	}
 
+	// 旧版本 IN 的多个值以逗号分隔，且不支持条件树
+	table := dynsql.Builder().
+		Filters(cols...).
+		Groups(gcols...).
+		Separator(",").
+		Depth(0).
+		Build()
 	shm := Schema{Fields: fields, Groups: groups}
 
 	return State{
-		fields: fieldMap,
-		groups: groupMap,
-		schema: shm,
+		fields:  fieldMap,
+		groups:  groupMap,
+		table:   table,
+		schemas: fields,
+		schema:  shm,
 	}
 }
@@ -3,6 +3,7 @@ package opencond
 import (
 	"fmt"
 
+	"github.com/vela-ssoc/backend-common/dynsql"
 	"gorm.io/gorm/clause"
 )
 
@@ -12,11 +13,11 @@ type Schema struct {
 }
 
 type State struct {
-	patterns map[string]*pattern
-	schemas  []*schema
-	fields   map[string]*pattern
-	groups   map[string]*pair
-	schema   Schema
+	fields  map[string]*pattern
+	groups  map[string]*pair
+	table   dynsql.Table
+	schemas []*schema
+	schema  Schema
 }
 
 // Schemas 获取约束
@@ -29,80 +30,71 @@ func (st State) Schema() Schema {
 	return st.schema
 }
 
+// Table 对应的 dynsql 表，新代码请直接使用 dynsql。
+func (st State) Table() dynsql.Table {
+	return st.table
+}
+
 // Interpreter 根据传输的环境参数解释出 gorm 表达式
 func (st State) Interpreter(envs Environments) (clause.Expression, error) {
-	exprs := make([]clause.Expression, 0, len(envs))
+	filters := make([]*dynsql.Filter, 0, len(envs))
 	for _, env := range envs {
 		key, val := env.Key, env.Value
 		if key == "" || val == "" { // value 为空就忽略
 			continue
 		}
 
-		pt := st.patterns[key]
-		if pt == nil {
-			return nil, fmt.Errorf("%s不存在", key)
-		}
-
-		opt := env.Operator
-		op := pt.operators[opt]
-		if op == nil {
-			return nil, fmt.Errorf("%s不支持%s表达式", key, opt)
-		}
-
-		expr, err := op.interpreter(pt, env)
+		f, err := st.filter(key, env.Operator, val)
 		if err != nil {
 			return nil, err
 		}
-
-		exprs = append(exprs, expr)
-	}
-	if len(exprs) == 0 {
-		return nil, nil
+		filters = append(filters, f)
 	}
 
-	return clause.AndConditions{Exprs: exprs}, nil
+	return st.interp(dynsql.Input{Filters: filters})
 }
 
+// Interp 解释查询字段，groupBy 只做白名单校验，分组请使用 Table().Inter 返回的 Scope。
 func (st State) Interp(fields Fields, groupBy string) (clause.Expression, error) {
-	length := len(fields)
-	if length == 0 && groupBy == "" {
-		return nil, nil
-	}
-
-	exps := make([]clause.Expression, length+1)
 	if groupBy != "" {
 		if _, ok := st.groups[groupBy]; !ok {
 			return nil, fmt.Errorf("%s不允许groupBy", groupBy)
 		}
-
-		gb := clause.GroupBy{Columns: []clause.Column{{Name: groupBy}}}
-		exps = append(exps, gb)
 	}
 
-	for _, f := range fields {
-		key := f.Key
-		pt := st.fields[key]
-		if pt == nil {
-			return nil, fmt.Errorf("%s不存在", key)
-		}
-
-		opt := f.Operator
-		op := pt.operators[opt]
-		if op == nil {
-			return nil, fmt.Errorf("%s不支持%s表达式", key, opt)
-		}
-
-		expr, err := op.interpreter(pt, nil)
+	filters := make([]*dynsql.Filter, 0, len(fields))
+	for _, fd := range fields {
+		f, err := st.filter(fd.Key, fd.Operator, fd.Value)
 		if err != nil {
 			return nil, err
 		}
+		filters = append(filters, f)
+	}
 
-		exps = append(exps, expr)
+	return st.interp(dynsql.Input{Filters: filters})
+}
+
+// filter 将旧版本的 key operator value 转为 dynsql 的过滤条件
+func (st State) filter(key, op, val string) (*dynsql.Filter, error) {
+	pt := st.fields[key]
+	if pt == nil {
+		return nil, fmt.Errorf("%s不存在", key)
+	}
+	if pt.operators[op] == nil {
+		return nil, fmt.Errorf("%s不支持%s表达式", key, op)
 	}
 
-	if len(exps) == 0 {
+	return &dynsql.Filter{Col: pt.column, Op: op, Val: val}, nil
+}
+
+func (st State) interp(input dynsql.Input) (clause.Expression, error) {
+	if len(input.Filters) == 0 || st.table == nil {
 		return nil, nil
 	}
+	sc, err := st.table.Inter(input)
+	if err != nil {
+		return nil, err
+	}
 
-	return clause.AndConditions{Exprs: exps}, nil
+	return sc.Condition(), nil
 }
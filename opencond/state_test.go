@@ -0,0 +1,58 @@
+package opencond
+
+import (
+	"strings"
+	"testing"
+
+	"gorm.io/gorm/clause"
+)
+
+type sqlBuilder struct {
+	strings.Builder
+}
+
+func (sb *sqlBuilder) WriteQuoted(field any) {
+	if col, ok := field.(string); ok {
+		sb.WriteString("`" + col + "`")
+	}
+}
+
+func (sb *sqlBuilder) AddVar(w clause.Writer, vars ...any) {
+	for i := range vars {
+		if i > 0 {
+			_, _ = w.WriteString(",")
+		}
+		_, _ = w.WriteString("?")
+	}
+}
+
+func (sb *sqlBuilder) AddError(err error) error { return err }
+
+func TestInterpreter(t *testing.T) {
+	st := Patterns{
+		Builder("name", "minion_name", "名字", TypeString).Build(),
+		Builder("status", "status", "状态", TypeInt).
+			Enums(Pairs{{Key: "1", Desc: "在线"}, {Key: "2", Desc: "离线"}}).Build(),
+	}.State()
+
+	expr, err := st.Interpreter(Environments{
+		{Key: "name", Operator: "like", Value: "web"},
+		{Key: "status", Operator: "in", Value: "1,2"},
+		{Key: "status", Operator: "eq", Value: ""}, // 空值忽略
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sb := new(sqlBuilder)
+	expr.Build(sb)
+	if want := "(`minion_name` LIKE ? AND `status` IN (?,?))"; sb.String() != want {
+		t.Fatalf("unexpected sql: %s", sb.String())
+	}
+
+	if _, err = st.Interpreter(Environments{{Key: "status", Operator: "eq", Value: "3"}}); err == nil {
+		t.Fatal("expected enum error")
+	}
+	if _, err = st.Interpreter(Environments{{Key: "status", Operator: "like", Value: "1"}}); err == nil {
+		t.Fatal("expected operator error")
+	}
+}
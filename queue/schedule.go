@@ -0,0 +1,58 @@
+package queue
+
+import (
+	"context"
+	"time"
+)
+
+// Every 每隔 interval 向 t 提交一次 rn，直到 ctx 取消。
+func Every(ctx context.Context, t Tasker, interval time.Duration, rn Runner) {
+	if interval <= 0 || rn == nil {
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				t.Submit(rn)
+			}
+		}
+	}()
+}
+
+// Daily 每天的 hour:minute（本地时间）向 t 提交一次 rn，直到 ctx 取消，
+// 适用于夜间执行的维护任务。
+func Daily(ctx context.Context, t Tasker, hour, minute int, rn Runner) {
+	if rn == nil {
+		return
+	}
+
+	go func() {
+		for {
+			timer := time.NewTimer(time.Until(nextDaily(time.Now(), hour, minute)))
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return
+			case <-timer.C:
+				t.Submit(rn)
+			}
+		}
+	}()
+}
+
+// nextDaily 计算 now 之后的下一个 hour:minute
+func nextDaily(now time.Time, hour, minute int) time.Time {
+	next := time.Date(now.Year(), now.Month(), now.Day(), hour, minute, 0, 0, now.Location())
+	if !next.After(now) {
+		next = next.AddDate(0, 0, 1)
+	}
+
+	return next
+}
@@ -0,0 +1,68 @@
+package queue
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestNextDaily(t *testing.T) {
+	shanghai := time.FixedZone("CST", 8*3600)
+	tests := []struct {
+		now  time.Time
+		hour int
+		min  int
+		want time.Time
+	}{
+		// 当天还没到
+		{time.Date(2023, 5, 10, 1, 30, 0, 0, shanghai), 3, 0, time.Date(2023, 5, 10, 3, 0, 0, 0, shanghai)},
+		// 当天已经过了
+		{time.Date(2023, 5, 10, 3, 0, 1, 0, shanghai), 3, 0, time.Date(2023, 5, 11, 3, 0, 0, 0, shanghai)},
+		// 正好是执行时间，下一次为明天
+		{time.Date(2023, 5, 10, 3, 0, 0, 0, shanghai), 3, 0, time.Date(2023, 5, 11, 3, 0, 0, 0, shanghai)},
+		// 跨月、跨年
+		{time.Date(2023, 4, 30, 23, 59, 0, 0, shanghai), 0, 10, time.Date(2023, 5, 1, 0, 10, 0, 0, shanghai)},
+		{time.Date(2023, 12, 31, 23, 30, 0, 0, shanghai), 3, 0, time.Date(2024, 1, 1, 3, 0, 0, 0, shanghai)},
+		// 闰年 2 月
+		{time.Date(2024, 2, 28, 12, 0, 0, 0, shanghai), 3, 0, time.Date(2024, 2, 29, 3, 0, 0, 0, shanghai)},
+		// 时区与 now 保持一致
+		{time.Date(2023, 5, 10, 20, 0, 0, 0, time.UTC), 3, 0, time.Date(2023, 5, 11, 3, 0, 0, 0, time.UTC)},
+	}
+	for _, tt := range tests {
+		if got := nextDaily(tt.now, tt.hour, tt.min); !got.Equal(tt.want) {
+			t.Errorf("nextDaily(%s, %d, %d) = %s, want %s", tt.now, tt.hour, tt.min, got, tt.want)
+		}
+	}
+}
+
+// countTasker 统计提交次数
+type countTasker struct{ submits chan Runner }
+
+func (ct *countTasker) Start()          {}
+func (ct *countTasker) Shutdown()       {}
+func (ct *countTasker) Submit(r Runner) { ct.submits <- r }
+
+type nopRunner struct{}
+
+func (nopRunner) Run() {}
+
+func TestEveryCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	tasker := &countTasker{submits: make(chan Runner, 16)}
+	Every(ctx, tasker, 5*time.Millisecond, nopRunner{})
+	select {
+	case <-tasker.submits:
+	case <-time.After(time.Second):
+		t.Fatal("runner not submitted")
+	}
+
+	cancel()
+	time.Sleep(20 * time.Millisecond)
+	for len(tasker.submits) > 0 {
+		<-tasker.submits
+	}
+	time.Sleep(20 * time.Millisecond)
+	if n := len(tasker.submits); n != 0 {
+		t.Fatalf("submitted %d times after cancel", n)
+	}
+}
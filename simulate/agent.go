@@ -0,0 +1,71 @@
+package simulate
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/vela-ssoc/backend-common/spdy"
+	"github.com/vela-ssoc/backend-common/transmit"
+)
+
+const (
+	arrPrefix = "/api/v1/arr/"
+	awsPrefix = "/api/v1/aws/"
+)
+
+// Agent 模拟的 agent(minion) 节点
+type Agent struct {
+	ID       int64 // minion ID
+	BrokerID int64 // 所属 broker ID
+
+	mux     *http.ServeMux
+	link    spdy.Muxer
+	server  *http.Server
+	client  transmit.Client
+	streamr transmit.Streamer
+}
+
+func newAgent(id, bid int64, link spdy.Muxer) *Agent {
+	ag := &Agent{ID: id, BrokerID: bid, mux: http.NewServeMux(), link: link}
+	dial := single(link)
+	ag.client = transmit.NewClient(&http.Transport{DialContext: dial})
+	ag.streamr = transmit.NewStream(dial)
+	ag.server = serve(link, http.HandlerFunc(ag.route))
+
+	return ag
+}
+
+// Handle 注册 agent 的脚本化处理方法，path 为 opcode.BArr opcode.BAws
+// 中的 path 部分（不含 /api/v1/arr/ 前缀），如：Handle("/ping", h)。
+func (ag *Agent) Handle(path string, h http.Handler) {
+	ag.mux.Handle(path, h)
+}
+
+// HandleFunc 同 Handle
+func (ag *Agent) HandleFunc(path string, fn func(http.ResponseWriter, *http.Request)) {
+	ag.mux.HandleFunc(path, fn)
+}
+
+// Client agent 调用 broker 的客户端
+func (ag *Agent) Client() transmit.Client { return ag.client }
+
+// Stream agent 与 broker 建立 websocket
+func (ag *Agent) Stream() transmit.Streamer { return ag.streamr }
+
+// route 去掉 /api/v1/arr/ /api/v1/aws/ 前缀后交给用户注册的路由
+func (ag *Agent) route(w http.ResponseWriter, r *http.Request) {
+	path := r.URL.Path
+	for _, prefix := range []string{arrPrefix, awsPrefix} {
+		if strings.HasPrefix(path, prefix) {
+			r.URL.Path = "/" + strings.TrimPrefix(path, prefix)
+			r.URL.RawPath = ""
+			break
+		}
+	}
+	ag.mux.ServeHTTP(w, r)
+}
+
+func (ag *Agent) close() {
+	_ = ag.server.Close()
+	_ = ag.link.Close()
+}
@@ -0,0 +1,166 @@
+package simulate
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/gorilla/websocket"
+	"github.com/vela-ssoc/backend-common/spdy"
+	"github.com/vela-ssoc/backend-common/transmit"
+	"github.com/vela-ssoc/backend-common/transmit/opcode"
+	"github.com/vela-ssoc/backend-common/transmit/opurl"
+)
+
+const (
+	brrPrefix   = "/api/v1/brr/"
+	bwsPrefix   = "/api/v1/bws/"
+	edictPrefix = "/api/v1/edict/"
+)
+
+// Broker 模拟的 broker 节点
+type Broker struct {
+	ID int64 // broker ID
+
+	// Mux manager 通过 opcode.MBrr opcode.MBws 调用 broker 时的路由，
+	// 注册的 path 不含 /api/v1/brr/ /api/v1/bws/ 前缀。
+	Mux *http.ServeMux
+
+	// AgentMux agent 主动请求 broker 时的路由，请求头中会带上
+	// opcode.HeaderXMinionID。
+	AgentMux *http.ServeMux
+
+	// Edict manager 下发的 edict 事件分发器
+	Edict *transmit.EdictDispatcher
+
+	link    spdy.Muxer // 与 manager 的连接
+	agents  *links     // 与 agent 的连接
+	trip    http.RoundTripper
+	client  transmit.Client
+	streamr transmit.Streamer
+	forward transmit.Forwarder
+	upgrade websocket.Upgrader
+	mgrCli  transmit.Client
+	mutex   sync.Mutex
+	servers []*http.Server
+}
+
+func newBroker(id int64, link spdy.Muxer) *Broker {
+	name := "broker-" + strconv.FormatInt(id, 10)
+	lk := newLinks()
+	trip := lk.transport()
+	bk := &Broker{
+		ID:       id,
+		Mux:      http.NewServeMux(),
+		AgentMux: http.NewServeMux(),
+		Edict:    transmit.NewEdict(name, 0, 0),
+		link:     link,
+		agents:   lk,
+		trip:     trip,
+		client:   transmit.NewClient(trip),
+		streamr:  lk.stream(),
+		forward:  transmit.NewForward(trip, name),
+		mgrCli:   transmit.NewClient(&http.Transport{DialContext: single(link)}),
+		upgrade:  transmit.Upgrade(name),
+	}
+	bk.addServer(serve(link, http.HandlerFunc(bk.route)))
+
+	return bk
+}
+
+// Transport 通往各个 agent 的 http.RoundTripper，URL 的 host 为 minion ID。
+func (bk *Broker) Transport() http.RoundTripper { return bk.trip }
+
+// Client 通过 opcode.BArr 构造的地址调用 agent。
+func (bk *Broker) Client() transmit.Client { return bk.client }
+
+// Stream 通过 opcode.BAws 构造的地址与 agent 建立 websocket。
+func (bk *Broker) Stream() transmit.Streamer { return bk.streamr }
+
+// ManagerClient broker 调用 manager 的客户端
+func (bk *Broker) ManagerClient() transmit.Client { return bk.mgrCli }
+
+// route 处理 manager 发来的请求
+func (bk *Broker) route(w http.ResponseWriter, r *http.Request) {
+	path := r.URL.Path
+	switch {
+	case strings.HasPrefix(path, arrPrefix):
+		mid, rest := splitMinion(strings.TrimPrefix(path, arrPrefix))
+		op := opurl.OpRR(mid, arrPrefix+rest, r.URL.RawQuery)
+		bk.forward.Forward(op, w, r)
+	case strings.HasPrefix(path, awsPrefix):
+		bk.pipeAgent(w, r)
+	case strings.HasPrefix(path, brrPrefix):
+		bk.serveLocal(brrPrefix, w, r)
+	case strings.HasPrefix(path, bwsPrefix):
+		bk.serveLocal(bwsPrefix, w, r)
+	case strings.HasPrefix(path, edictPrefix):
+		bk.Edict.ServeHTTP(w, r)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// pipeAgent 将 manager 的 websocket 与 agent 的 websocket 对接
+func (bk *Broker) pipeAgent(w http.ResponseWriter, r *http.Request) {
+	mid, rest := splitMinion(strings.TrimPrefix(r.URL.Path, awsPrefix))
+	op := opcode.BAws(mid, rest, r.URL.RawQuery)
+	back, res, err := transmit.StreamContext(r.Context(), bk.streamr, op, nil, nil)
+	if err != nil {
+		code := http.StatusBadGateway
+		if res != nil {
+			code = res.StatusCode
+		}
+		http.Error(w, err.Error(), code)
+		return
+	}
+	fore, err := bk.upgrade.Upgrade(w, r, nil)
+	if err != nil {
+		_ = back.Close()
+		return
+	}
+	transmit.Pipe(fore, back)
+}
+
+func (bk *Broker) serveLocal(prefix string, w http.ResponseWriter, r *http.Request) {
+	r.URL.Path = "/" + strings.TrimPrefix(r.URL.Path, prefix)
+	r.URL.RawPath = ""
+	bk.Mux.ServeHTTP(w, r)
+}
+
+// serveAgent 处理 agent 发来的请求
+func (bk *Broker) serveAgent(mid int64) http.Handler {
+	id := strconv.FormatInt(mid, 10)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.Header.Set(opcode.HeaderXMinionID, id)
+		bk.AgentMux.ServeHTTP(w, r)
+	})
+}
+
+func (bk *Broker) addAgent(mid int64, link spdy.Muxer) {
+	bk.agents.put(mid, link)
+	bk.addServer(serve(link, bk.serveAgent(mid)))
+}
+
+func (bk *Broker) addServer(srv *http.Server) {
+	bk.mutex.Lock()
+	bk.servers = append(bk.servers, srv)
+	bk.mutex.Unlock()
+}
+
+func (bk *Broker) close() {
+	bk.mutex.Lock()
+	for _, srv := range bk.servers {
+		_ = srv.Close()
+	}
+	bk.mutex.Unlock()
+	bk.agents.close()
+	_ = bk.link.Close()
+}
+
+// splitMinion 将 "{mid}/path" 拆分为 mid 与 path
+func splitMinion(s string) (string, string) {
+	mid, rest, _ := strings.Cut(s, "/")
+	return mid, rest
+}
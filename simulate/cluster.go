@@ -0,0 +1,90 @@
+package simulate
+
+import (
+	"net/http"
+	"sort"
+
+	"github.com/vela-ssoc/backend-common/spdy"
+)
+
+// Cluster 模拟集群
+type Cluster struct {
+	Manager *Manager
+	brokers map[int64]*Broker
+	agents  map[int64]*Agent
+}
+
+// New 新建模拟集群：brokers 个 broker（ID 从 1 开始），每个 broker 下挂
+// agents 个 agent（minion ID 全局从 1 开始递增），opts 会同时作用于所有
+// spdy 连接（如 spdy.WithEncrypt）。
+func New(brokers, agents int, opts ...spdy.Option) *Cluster {
+	c := &Cluster{
+		Manager: newManager(),
+		brokers: make(map[int64]*Broker, brokers),
+		agents:  make(map[int64]*Agent, brokers*agents),
+	}
+
+	var mid int64
+	for i := 1; i <= brokers; i++ {
+		bid := int64(i)
+		srv, cli := pair(opts)
+		c.Manager.links.put(bid, srv)
+		bk := newBroker(bid, cli)
+		c.Manager.serve(bid, c.Manager.Mux)
+		c.brokers[bid] = bk
+
+		for j := 0; j < agents; j++ {
+			mid++
+			asrv, acli := pair(opts)
+			bk.addAgent(mid, asrv)
+			c.agents[mid] = newAgent(mid, bid, acli)
+		}
+	}
+
+	return c
+}
+
+// Broker 通过 ID 获取 broker
+func (c *Cluster) Broker(bid int64) *Broker { return c.brokers[bid] }
+
+// Agent 通过 minion ID 获取 agent
+func (c *Cluster) Agent(mid int64) *Agent { return c.agents[mid] }
+
+// Brokers 按照 ID 升序返回所有 broker
+func (c *Cluster) Brokers() []*Broker {
+	ret := make([]*Broker, 0, len(c.brokers))
+	for _, bk := range c.brokers {
+		ret = append(ret, bk)
+	}
+	sort.Slice(ret, func(i, j int) bool { return ret[i].ID < ret[j].ID })
+	return ret
+}
+
+// Agents 按照 ID 升序返回所有 agent
+func (c *Cluster) Agents() []*Agent {
+	ret := make([]*Agent, 0, len(c.agents))
+	for _, ag := range c.agents {
+		ret = append(ret, ag)
+	}
+	sort.Slice(ret, func(i, j int) bool { return ret[i].ID < ret[j].ID })
+	return ret
+}
+
+// HandleAll 为所有 agent 注册同一个处理方法
+func (c *Cluster) HandleAll(path string, fn func(ag *Agent, w http.ResponseWriter, r *http.Request)) {
+	for _, ag := range c.agents {
+		ag := ag
+		ag.HandleFunc(path, func(w http.ResponseWriter, r *http.Request) { fn(ag, w, r) })
+	}
+}
+
+// Close 关闭所有节点与连接
+func (c *Cluster) Close() {
+	for _, ag := range c.agents {
+		ag.close()
+	}
+	for _, bk := range c.brokers {
+		bk.close()
+	}
+	c.Manager.close()
+}
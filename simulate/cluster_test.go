@@ -0,0 +1,124 @@
+package simulate_test
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/vela-ssoc/backend-common/simulate"
+	"github.com/vela-ssoc/backend-common/transmit"
+	"github.com/vela-ssoc/backend-common/transmit/opcode"
+	"github.com/vela-ssoc/backend-common/transmit/opdata"
+)
+
+func TestCluster(t *testing.T) {
+	cls := simulate.New(2, 3)
+	defer cls.Close()
+
+	cls.HandleAll("/ping", func(ag *simulate.Agent, w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(map[string]int64{"id": ag.ID})
+	})
+	cls.HandleAll("/echo", func(ag *simulate.Agent, w http.ResponseWriter, r *http.Request) {
+		up := transmit.Upgrade("agent")
+		conn, err := up.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		//goland:noinspection GoUnhandledErrorResult
+		defer conn.Close()
+		for {
+			mt, p, err := conn.ReadMessage()
+			if err != nil || conn.WriteMessage(mt, p) != nil {
+				return
+			}
+		}
+	})
+	cls.HandleAll("/download", func(ag *simulate.Agent, w http.ResponseWriter, r *http.Request) {
+		cd := mime.FormatMediaType("attachment", map[string]string{"filename": "ssoc.bin", "checksum": "abc"})
+		w.Header().Set("Content-Disposition", cd)
+		_, _ = w.Write([]byte(strings.Repeat("x", 100*1024)))
+	})
+	for _, bk := range cls.Brokers() {
+		bk := bk
+		transmit.HandleEdict(bk.Edict, opcode.EdictCommandEvent, func(ctx context.Context, mid int64, cmd opdata.Command) error {
+			op := opcode.BArr(strconv.FormatInt(mid, 10), http.MethodGet, "ping", "")
+			var ret map[string]int64
+			return bk.Client().JSON(ctx, op, nil, &ret)
+		})
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	mgr := cls.Manager
+
+	t.Run("request-response", func(t *testing.T) {
+		for _, ag := range cls.Agents() {
+			var ret map[string]int64
+			op := opcode.MArr(ag.BrokerID, ag.ID, http.MethodGet, "ping", "")
+			if err := mgr.Client().JSON(ctx, op, nil, &ret); err != nil {
+				t.Fatal(err)
+			}
+			if ret["id"] != ag.ID {
+				t.Errorf("期望 agent %d 响应，实际为 %d", ag.ID, ret["id"])
+			}
+		}
+	})
+
+	t.Run("websocket", func(t *testing.T) {
+		ag := cls.Agent(5)
+		op := opcode.MAws(ag.BrokerID, ag.ID, "echo", "")
+		conn, _, err := transmit.StreamContext(ctx, mgr.Stream(), op, nil, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		//goland:noinspection GoUnhandledErrorResult
+		defer conn.Close()
+		if err = conn.WriteMessage(websocket.TextMessage, []byte("hello")); err != nil {
+			t.Fatal(err)
+		}
+		if _, p, err := conn.ReadMessage(); err != nil || string(p) != "hello" {
+			t.Errorf("websocket 回显错误：%q %v", p, err)
+		}
+	})
+
+	t.Run("fan-out", func(t *testing.T) {
+		send := opdata.ManagerSend{MinionIDs: []int64{1, 2, 3, 99}, Original: opdata.Command{Cmd: "ping"}}
+		var report opdata.EdictReport
+		op := opcode.EdictCommandEvent.SetIntID(1)
+		if err := mgr.Client().JSON(ctx, op, send, &report); err != nil {
+			t.Fatal(err)
+		}
+		if report.Succeed != 3 || report.Failed != 1 {
+			t.Errorf("期望成功 3 个失败 1 个，实际：%+v", report)
+		}
+	})
+
+	t.Run("download", func(t *testing.T) {
+		op := opcode.MArr(1, 2, http.MethodGet, "download", "")
+		att, err := mgr.Client().Attachment(ctx, op)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var n int
+		sum, err := att.Copy(writerFunc(func(p []byte) (int, error) { n += len(p); return len(p), nil }))
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := sha1.Sum([]byte(strings.Repeat("x", 100*1024)))
+		if att.Filename != "ssoc.bin" || n != 100*1024 || sum != hex.EncodeToString(want[:]) {
+			t.Errorf("下载文件错误：%s %d %s", att.Filename, n, sum)
+		}
+	})
+}
+
+type writerFunc func([]byte) (int, error)
+
+func (f writerFunc) Write(p []byte) (int, error) { return f(p) }
@@ -0,0 +1,16 @@
+// Package simulate 进程内的 manager/broker/agent 模拟集群，用于在 go test
+// 中联调 transmit spdy opcode 的路由逻辑，无需真实部署，也不占用网络端口。
+//
+// 节点之间使用 net.Pipe 连接，并在两端分别建立 spdy 多路复用：
+//
+//	manager <--spdy--> broker-1 <--spdy--> agent-1 agent-2 ...
+//	        <--spdy--> broker-2 <--spdy--> agent-3 agent-4 ...
+//
+// broker 会按照 opcode 约定的路径转发 manager 的请求：
+// /api/v1/arr/{mid}/* /api/v1/aws/{mid}/* 转发到对应的 agent，
+// /api/v1/brr/* /api/v1/bws/* 由 broker 自己处理，
+// /api/v1/edict/* 交由 broker 的 transmit.EdictDispatcher 分发。
+//
+// 集群中的 spdy 流会被多个 goroutine 同时读写、关闭，修改 spdy transmit 后
+// 应当使用 go test -race ./simulate/ 检查数据竞争。
+package simulate
@@ -0,0 +1,90 @@
+package simulate
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+
+	"github.com/vela-ssoc/backend-common/spdy"
+	"github.com/vela-ssoc/backend-common/transmit"
+)
+
+// links 按照节点 ID 管理 spdy 连接，并据此拨号
+type links struct {
+	mutex sync.RWMutex
+	muxes map[int64]spdy.Muxer
+}
+
+func newLinks() *links {
+	return &links{muxes: make(map[int64]spdy.Muxer, 16)}
+}
+
+func (l *links) put(id int64, mux spdy.Muxer) {
+	l.mutex.Lock()
+	l.muxes[id] = mux
+	l.mutex.Unlock()
+}
+
+func (l *links) get(id int64) spdy.Muxer {
+	l.mutex.RLock()
+	mux := l.muxes[id]
+	l.mutex.RUnlock()
+	return mux
+}
+
+// dialContext 将 URL 中的 host 解析为节点 ID，通过对应的 spdy 连接打开新的 stream
+func (l *links) dialContext(_ context.Context, _, addr string) (net.Conn, error) {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		host = addr
+	}
+	id, err := strconv.ParseInt(host, 10, 64)
+	if err != nil {
+		return nil, &net.AddrError{Err: "invalid node id", Addr: addr}
+	}
+	mux := l.get(id)
+	if mux == nil {
+		return nil, &net.AddrError{Err: "node offline", Addr: addr}
+	}
+
+	return mux.Dial()
+}
+
+func (l *links) transport() http.RoundTripper {
+	return &http.Transport{DialContext: l.dialContext}
+}
+
+func (l *links) stream() transmit.Streamer {
+	return transmit.NewStream(l.dialContext)
+}
+
+func (l *links) close() {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	for id, mux := range l.muxes {
+		_ = mux.Close()
+		delete(l.muxes, id)
+	}
+}
+
+// single 固定拨号到同一个 spdy 连接，用于 agent->broker broker->manager 方向
+func single(mux spdy.Muxer) func(context.Context, string, string) (net.Conn, error) {
+	return func(context.Context, string, string) (net.Conn, error) {
+		return mux.Dial()
+	}
+}
+
+// serve 在 spdy 连接上启动 HTTP 服务
+func serve(mux spdy.Muxer, h http.Handler) *http.Server {
+	srv := &http.Server{Handler: h}
+	go func() { _ = srv.Serve(mux) }()
+	return srv
+}
+
+// pair 通过 net.Pipe 建立一对 spdy 连接
+func pair(opts []spdy.Option) (server, client spdy.Muxer) {
+	a, b := net.Pipe()
+	return spdy.Server(a, opts...), spdy.Client(b, opts...)
+}
@@ -0,0 +1,58 @@
+package simulate
+
+import (
+	"net/http"
+	"sync"
+
+	"github.com/vela-ssoc/backend-common/transmit"
+)
+
+// Manager 模拟的中心端
+type Manager struct {
+	// Mux broker 主动请求 manager 时的路由
+	Mux *http.ServeMux
+
+	links   *links
+	trip    http.RoundTripper
+	client  transmit.Client
+	streamr transmit.Streamer
+	mutex   sync.Mutex
+	servers []*http.Server
+}
+
+func newManager() *Manager {
+	lk := newLinks()
+	trip := lk.transport()
+	return &Manager{
+		Mux:     http.NewServeMux(),
+		links:   lk,
+		trip:    trip,
+		client:  transmit.NewClient(trip),
+		streamr: lk.stream(),
+	}
+}
+
+// Transport 通往各个 broker 的 http.RoundTripper，URL 的 host 为 broker ID。
+func (m *Manager) Transport() http.RoundTripper { return m.trip }
+
+// Client 通过 opcode.MArr opcode.MBrr 等构造的地址调用 broker/agent。
+func (m *Manager) Client() transmit.Client { return m.client }
+
+// Stream 通过 opcode.MAws opcode.MBws 等构造的地址建立 websocket。
+func (m *Manager) Stream() transmit.Streamer { return m.streamr }
+
+func (m *Manager) serve(bid int64, h http.Handler) {
+	srv := serve(m.links.get(bid), h)
+	m.mutex.Lock()
+	m.servers = append(m.servers, srv)
+	m.mutex.Unlock()
+}
+
+func (m *Manager) close() {
+	m.links.close()
+	m.mutex.Lock()
+	for _, srv := range m.servers {
+		_ = srv.Close()
+	}
+	m.mutex.Unlock()
+}
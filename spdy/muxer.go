@@ -6,6 +6,8 @@ import (
 	"net"
 	"sync"
 	"sync/atomic"
+
+	"github.com/vela-ssoc/backend-common/throttle"
 )
 
 type muxer struct {
@@ -18,6 +20,7 @@ type muxer struct {
 	passwd  []byte
 	pwn     int
 	prn     int
+	limits  throttle.Limiters // 写入限速
 	ctx     context.Context
 	cancel  context.CancelFunc
 }
@@ -94,14 +97,16 @@ func (mux *muxer) newStream() (*stream, error) {
 
 func (mux *muxer) synStream(stmID uint32) *stream {
 	ctx, cancel := context.WithCancel(mux.ctx)
-	return &stream{
+	stm := &stream{
 		id:        stmID,
-		syn:       true,
 		mux:       mux,
 		readEvtCh: make(chan struct{}, 1),
 		ctx:       ctx,
 		cancel:    cancel,
 	}
+	stm.syn.Store(true)
+
+	return stm
 }
 
 func (mux *muxer) putStream(stm *stream) {
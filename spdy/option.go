@@ -3,6 +3,8 @@ package spdy
 import (
 	"context"
 	"net"
+
+	"github.com/vela-ssoc/backend-common/throttle"
 )
 
 type option struct {
@@ -11,6 +13,7 @@ type option struct {
 	capacity int
 	server   bool
 	passwd   []byte
+	limits   throttle.Limiters
 }
 
 type Option func(*option)
@@ -39,6 +42,13 @@ func WithEncrypt(passwd []byte) Option {
 	}
 }
 
+// WithLimiter 限制写入带宽，可以同时传入全局、broker、agent 等多级限速器。
+func WithLimiter(lims ...*throttle.Limiter) Option {
+	return func(opt *option) {
+		opt.limits = append(opt.limits, lims...)
+	}
+}
+
 func (opt option) muxer(tran net.Conn) *muxer {
 	backlog := opt.backlog
 	capacity := opt.capacity
@@ -55,6 +65,7 @@ func (opt option) muxer(tran net.Conn) *muxer {
 		streams: make(map[uint32]*stream, capacity),
 		accepts: make(chan *stream, backlog),
 		passwd:  opt.passwd,
+		limits:  opt.limits,
 		ctx:     ctx,
 		cancel:  cancel,
 	}
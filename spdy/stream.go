@@ -13,7 +13,7 @@ import (
 type stream struct {
 	id           uint32
 	mux          *muxer
-	syn          bool        // 是否已经发送了握手帧
+	syn          atomic.Bool // 是否已经发送了握手帧（Write 与 Close 可能并发访问）
 	wmu          sync.Mutex  // 数据写锁
 	rwn          sync.Mutex  // 数据读锁
 	buff         []byte      // 消息缓冲池
@@ -21,7 +21,7 @@ type stream struct {
 	closed       atomic.Bool // 保证 close 方法只被执行一次
 	ctx          context.Context
 	cancel       context.CancelFunc
-	readDeadline time.Time     // readDeadline
+	readDeadline atomic.Int64  // readDeadline（UnixNano，0 代表不超时）
 	readEvtCh    chan struct{} // 读取事件通知 channel
 }
 
@@ -37,7 +37,11 @@ func (stm *stream) SetDeadline(t time.Time) (err error) {
 }
 
 func (stm *stream) SetReadDeadline(t time.Time) error {
-	stm.readDeadline = t
+	var dead int64
+	if !t.IsZero() {
+		dead = t.UnixNano()
+	}
+	stm.readDeadline.Store(dead)
 	stm.notifyReadEvt()
 	return nil
 }
@@ -80,8 +84,7 @@ func (stm *stream) Write(p []byte) (int, error) {
 	defer stm.wmu.Unlock()
 
 	flag := flagDAT
-	if !stm.syn {
-		stm.syn = true
+	if !stm.syn.Load() {
 		flag = flagSYN
 	}
 
@@ -91,9 +94,14 @@ func (stm *stream) Write(p []byte) (int, error) {
 			n = maximum
 		}
 
+		if err := stm.mux.limits.WaitN(stm.ctx, n); err != nil {
+			return psz - len(p), err
+		}
 		if _, err := stm.mux.write(flag, stm.id, p[:n]); err != nil {
 			return 0, err
 		}
+		// SYN 帧发送成功后才算建立，限流等待失败时下次写入仍然要发送 SYN
+		stm.syn.Store(true)
 
 		flag = flagDAT
 		p = p[n:]
@@ -132,8 +140,8 @@ func (stm *stream) read(p []byte) (bool, int) {
 
 func (stm *stream) readBlocking() error {
 	var deadline <-chan time.Time
-	if dead := stm.readDeadline; !dead.IsZero() {
-		timer := time.NewTimer(time.Until(dead))
+	if dead := stm.readDeadline.Load(); dead != 0 {
+		timer := time.NewTimer(time.Until(time.Unix(0, dead)))
 		defer timer.Stop()
 		deadline = timer.C
 	}
@@ -156,7 +164,7 @@ func (stm *stream) closeError(err error, fin bool) error {
 	stmID := stm.id
 	stm.mux.delStream(stmID)
 
-	if fin && stm.syn {
+	if fin && stm.syn.Load() {
 		_, _ = stm.mux.write(flagFIN, stmID, nil)
 	}
 
@@ -0,0 +1,106 @@
+package throttle
+
+import "sync"
+
+// Group 分级限速配置：全局一个限速器，每个 broker 和每个 agent 各自一个
+// 限速器，同一个 ID 总是返回同一个限速器，修改速率后立即对所有传输生效。
+type Group struct {
+	global     *Limiter
+	mutex      sync.Mutex
+	brokerRate int64
+	agentRate  int64
+	brokers    map[int64]*Limiter
+	agents     map[int64]*Limiter
+}
+
+// NewGroup 新建分级限速，单位均为 bytes/s，<= 0 代表该级别不限速。
+func NewGroup(global, broker, agent int64) *Group {
+	return &Group{
+		global:     NewLimiter(global, 0),
+		brokerRate: broker,
+		agentRate:  agent,
+		brokers:    make(map[int64]*Limiter, 16),
+		agents:     make(map[int64]*Limiter, 256),
+	}
+}
+
+// Global 全局限速器
+func (g *Group) Global() *Limiter { return g.global }
+
+// Broker 获取 broker 的限速器
+func (g *Group) Broker(bid int64) *Limiter {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+
+	return g.load(g.brokers, bid, g.brokerRate)
+}
+
+// Agent 获取 agent 的限速器
+func (g *Group) Agent(mid int64) *Limiter {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+
+	return g.load(g.agents, mid, g.agentRate)
+}
+
+// Limiters 返回 agent 传输需要满足的全部限速器：全局、所属 broker、agent 自身。
+// bid 或 mid 小于等于 0 时跳过对应级别。
+func (g *Group) Limiters(bid, mid int64) Limiters {
+	ls := Limiters{g.global}
+	if bid > 0 {
+		ls = append(ls, g.Broker(bid))
+	}
+	if mid > 0 {
+		ls = append(ls, g.Agent(mid))
+	}
+	return ls
+}
+
+// SetGlobal 修改全局速率
+func (g *Group) SetGlobal(rate int64) {
+	g.global.SetRate(rate, 0)
+}
+
+// SetBroker 修改所有 broker 的默认速率
+func (g *Group) SetBroker(rate int64) {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+
+	g.brokerRate = rate
+	for _, l := range g.brokers {
+		l.SetRate(rate, 0)
+	}
+}
+
+// SetAgent 修改所有 agent 的默认速率
+func (g *Group) SetAgent(rate int64) {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+
+	g.agentRate = rate
+	for _, l := range g.agents {
+		l.SetRate(rate, 0)
+	}
+}
+
+// Forget 节点下线后释放其限速器
+func (g *Group) Forget(bid, mid int64) {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+
+	if bid > 0 {
+		delete(g.brokers, bid)
+	}
+	if mid > 0 {
+		delete(g.agents, mid)
+	}
+}
+
+func (g *Group) load(m map[int64]*Limiter, id, rate int64) *Limiter {
+	l, ok := m[id]
+	if !ok {
+		l = NewLimiter(rate, 0)
+		m[id] = l
+	}
+	return l
+}
@@ -0,0 +1,102 @@
+package throttle
+
+import (
+	"context"
+	"io"
+)
+
+// Limiters 多级限速器（如：全局、broker、agent），需要同时满足所有限速器。
+type Limiters []*Limiter
+
+// WaitN 依次从每个限速器获取 n 个令牌
+func (ls Limiters) WaitN(ctx context.Context, n int) error {
+	for _, l := range ls {
+		if err := l.WaitN(ctx, n); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// chunk 单次读写的最大字节数，取所有限速器 burst 的最小值，使流量更平滑。
+func (ls Limiters) chunk(n int) int {
+	for _, l := range ls {
+		if b := l.Burst(); b > 0 && b < n {
+			n = b
+		}
+	}
+	return n
+}
+
+// NewReader 包装限速的 io.Reader
+func NewReader(ctx context.Context, r io.Reader, lims ...*Limiter) io.Reader {
+	if len(lims) == 0 {
+		return r
+	}
+	return &reader{ctx: ctx, r: r, lims: lims}
+}
+
+// NewReadCloser 包装限速的 io.ReadCloser
+func NewReadCloser(ctx context.Context, rc io.ReadCloser, lims ...*Limiter) io.ReadCloser {
+	if len(lims) == 0 {
+		return rc
+	}
+	return &readCloser{reader: reader{ctx: ctx, r: rc, lims: lims}, c: rc}
+}
+
+// NewWriter 包装限速的 io.Writer
+func NewWriter(ctx context.Context, w io.Writer, lims ...*Limiter) io.Writer {
+	if len(lims) == 0 {
+		return w
+	}
+	return &writer{ctx: ctx, w: w, lims: lims}
+}
+
+type reader struct {
+	ctx  context.Context
+	r    io.Reader
+	lims Limiters
+}
+
+func (rd *reader) Read(p []byte) (int, error) {
+	if n := rd.lims.chunk(len(p)); n < len(p) {
+		p = p[:n]
+	}
+	n, err := rd.r.Read(p)
+	if n > 0 {
+		if ex := rd.lims.WaitN(rd.ctx, n); ex != nil && err == nil {
+			err = ex
+		}
+	}
+	return n, err
+}
+
+type readCloser struct {
+	reader
+	c io.Closer
+}
+
+func (rc *readCloser) Close() error { return rc.c.Close() }
+
+type writer struct {
+	ctx  context.Context
+	w    io.Writer
+	lims Limiters
+}
+
+func (wr *writer) Write(p []byte) (int, error) {
+	var total int
+	for len(p) > 0 {
+		n := wr.lims.chunk(len(p))
+		if err := wr.lims.WaitN(wr.ctx, n); err != nil {
+			return total, err
+		}
+		i, err := wr.w.Write(p[:n])
+		total += i
+		if err != nil {
+			return total, err
+		}
+		p = p[n:]
+	}
+	return total, nil
+}
@@ -0,0 +1,135 @@
+// Package throttle 令牌桶限速，用于限制 agent 升级包等文件传输占用的带宽。
+package throttle
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Limiter 令牌桶限速器，一个令牌代表一个字节，可以被多个 reader/writer
+// 共享以实现全局、broker 维度、agent 维度的限速。nil 或 rate <= 0 时不限速。
+type Limiter struct {
+	mutex  sync.Mutex
+	rate   float64   // 每秒产生的令牌数（bytes/s）
+	burst  int       // 桶容量
+	tokens float64   // 当前令牌数，可以为负数（代表欠账，后来者需要等待更久）
+	last   time.Time // 上次计算令牌的时间
+}
+
+// NewLimiter 新建限速器，rate 为每秒字节数，burst 为允许的突发字节数，
+// burst <= 0 时默认为 rate 的 1/10（至少 4K）。
+func NewLimiter(rate int64, burst int) *Limiter {
+	lim := new(Limiter)
+	lim.SetRate(rate, burst)
+	return lim
+}
+
+// SetRate 动态调整速率，rate <= 0 代表不限速。
+func (l *Limiter) SetRate(rate int64, burst int) {
+	if burst <= 0 {
+		burst = int(rate / 10)
+		if burst < 4*1024 {
+			burst = 4 * 1024
+		}
+	}
+
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	now := time.Now()
+	fresh := l.last.IsZero()
+	l.advance(now)
+	l.rate = float64(rate)
+	l.burst = burst
+	// 新建的限速器桶是满的
+	if fresh || l.tokens > float64(burst) {
+		l.tokens = float64(burst)
+	}
+}
+
+// Rate 当前速率（bytes/s），0 代表不限速。
+func (l *Limiter) Rate() int64 {
+	if l == nil {
+		return 0
+	}
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	return int64(l.rate)
+}
+
+// Burst 单次最多可以获取的令牌数
+func (l *Limiter) Burst() int {
+	if l == nil {
+		return 0
+	}
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	if l.rate <= 0 {
+		return 0
+	}
+	return l.burst
+}
+
+// WaitN 获取 n 个令牌，令牌不足时阻塞等待，ctx 取消时返回错误。
+func (l *Limiter) WaitN(ctx context.Context, n int) error {
+	if l == nil {
+		return nil
+	}
+	for n > 0 {
+		wait, take := l.reserve(n)
+		n -= take
+		if wait <= 0 {
+			continue
+		}
+		if ctx == nil {
+			time.Sleep(wait)
+			continue
+		}
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+
+	return nil
+}
+
+// reserve 预定最多 burst 个令牌，返回需要等待的时间与实际预定的令牌数。
+func (l *Limiter) reserve(n int) (time.Duration, int) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	if l.rate <= 0 {
+		return 0, n
+	}
+	if n > l.burst {
+		n = l.burst
+	}
+
+	l.advance(time.Now())
+	l.tokens -= float64(n)
+	if l.tokens >= 0 {
+		return 0, n
+	}
+	wait := time.Duration(-l.tokens / l.rate * float64(time.Second))
+
+	return wait, n
+}
+
+// advance 根据流逝的时间补充令牌
+func (l *Limiter) advance(now time.Time) {
+	if !l.last.IsZero() && l.rate > 0 {
+		elapsed := now.Sub(l.last).Seconds()
+		l.tokens += elapsed * l.rate
+		if max := float64(l.burst); l.tokens > max {
+			l.tokens = max
+		}
+	}
+	l.last = now
+}
@@ -0,0 +1,143 @@
+package throttle
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"testing"
+	"time"
+)
+
+func TestLimiterWaitN(t *testing.T) {
+	// 桶里初始有 burst 个令牌，剩下的 50K 按照 100K/s 需要约 500ms
+	lim := NewLimiter(100*1024, 10*1024)
+	start := time.Now()
+	if err := lim.WaitN(context.Background(), 60*1024); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed < 400*time.Millisecond || elapsed > 2*time.Second {
+		t.Fatalf("unexpected elapsed: %s", elapsed)
+	}
+}
+
+func TestLimiterUnlimited(t *testing.T) {
+	var nilLim *Limiter
+	for _, lim := range []*Limiter{nilLim, NewLimiter(0, 0)} {
+		start := time.Now()
+		if err := lim.WaitN(context.Background(), 1<<30); err != nil {
+			t.Fatal(err)
+		}
+		if elapsed := time.Since(start); elapsed > 100*time.Millisecond {
+			t.Fatalf("unlimited limiter waited %s", elapsed)
+		}
+		if lim.Burst() != 0 {
+			t.Fatalf("unexpected burst: %d", lim.Burst())
+		}
+	}
+}
+
+func TestLimiterCancel(t *testing.T) {
+	lim := NewLimiter(1024, 1024)
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	if err := lim.WaitN(ctx, 10*1024); err != context.DeadlineExceeded {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("cancel took %s", elapsed)
+	}
+}
+
+func TestLimiterSetRate(t *testing.T) {
+	lim := NewLimiter(1024, 1024)
+	if err := lim.WaitN(context.Background(), 1024); err != nil {
+		t.Fatal(err)
+	}
+	// 调整为不限速后，欠账的令牌不应继续阻塞
+	lim.SetRate(0, 0)
+	start := time.Now()
+	if err := lim.WaitN(context.Background(), 1<<20); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed > 100*time.Millisecond {
+		t.Fatalf("unlimited limiter waited %s", elapsed)
+	}
+	if lim.Rate() != 0 {
+		t.Fatalf("unexpected rate: %d", lim.Rate())
+	}
+}
+
+// chunkWriter 记录每次写入的长度
+type chunkWriter struct {
+	bytes.Buffer
+	sizes []int
+}
+
+func (cw *chunkWriter) Write(p []byte) (int, error) {
+	cw.sizes = append(cw.sizes, len(p))
+	return cw.Buffer.Write(p)
+}
+
+func TestWriterChunk(t *testing.T) {
+	// 写入按照所有限速器中最小的 burst 切分
+	lims := Limiters{NewLimiter(1<<30, 8*1024), NewLimiter(1<<30, 4*1024), nil}
+	cw := new(chunkWriter)
+	w := NewWriter(context.Background(), cw, lims...)
+	data := bytes.Repeat([]byte("x"), 10*1024)
+	if n, err := w.Write(data); err != nil || n != len(data) {
+		t.Fatalf("write %d: %v", n, err)
+	}
+	if !bytes.Equal(cw.Bytes(), data) {
+		t.Fatal("unexpected written data")
+	}
+	for _, size := range cw.sizes {
+		if size > 4*1024 {
+			t.Fatalf("unexpected chunk sizes: %v", cw.sizes)
+		}
+	}
+
+	if NewWriter(context.Background(), cw) != io.Writer(cw) {
+		t.Fatal("writer without limiter should not be wrapped")
+	}
+}
+
+func TestReaderCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	lim := NewLimiter(1024, 1024)
+	_ = lim.WaitN(context.Background(), 1024) // 耗尽初始令牌
+
+	rd := NewReader(ctx, bytes.NewReader(make([]byte, 8*1024)), lim)
+	buf := make([]byte, 8*1024)
+	n, err := rd.Read(buf)
+	if n != 1024 || err != context.Canceled {
+		t.Fatalf("read %d: %v", n, err)
+	}
+}
+
+func TestGroup(t *testing.T) {
+	g := NewGroup(0, 2048, 1024)
+	if g.Broker(1) != g.Broker(1) || g.Agent(1) != g.Agent(1) {
+		t.Fatal("same id should share one limiter")
+	}
+	if ls := g.Limiters(1, 0); len(ls) != 2 {
+		t.Fatalf("unexpected limiters: %d", len(ls))
+	}
+	if ls := g.Limiters(1, 2); len(ls) != 3 || ls[2] != g.Agent(2) {
+		t.Fatalf("unexpected limiters: %v", ls)
+	}
+
+	// 修改默认速率后已有的限速器立即生效
+	agent := g.Agent(2)
+	g.SetAgent(4096)
+	if agent.Rate() != 4096 || g.Agent(3).Rate() != 4096 {
+		t.Fatalf("unexpected agent rate: %d", agent.Rate())
+	}
+
+	g.Forget(0, 2)
+	if g.Agent(2) == agent {
+		t.Fatal("forgotten agent should get a new limiter")
+	}
+}
@@ -1,11 +1,14 @@
 package transmit
 
 import (
+	"context"
 	"crypto/sha1"
 	"encoding/hex"
 	"io"
 	"net/http"
 	"os"
+
+	"github.com/vela-ssoc/backend-common/throttle"
 )
 
 // Attachment 文件附件下载
@@ -21,10 +24,17 @@ func (att Attachment) NotModified() bool {
 	return att.code == http.StatusNotModified
 }
 
+// Throttle 限制下载带宽，可以同时传入全局、broker、agent 等多级限速器，
+// 一般配合 throttle.Group 的 Limiters 方法使用。
+func (att Attachment) Throttle(ctx context.Context, lims ...*throttle.Limiter) Attachment {
+	att.rc = throttle.NewReadCloser(ctx, att.rc, lims...)
+	return att
+}
+
 // Copy 写入到指定的流中，返回写入流的 SHA-1
 func (att Attachment) Copy(dst io.Writer) (string, error) {
 	hash := sha1.New()
-	rd := io.TeeReader(att.rc, dst)
+	rd := io.TeeReader(att.rc, hash)
 	//goland:noinspection GoUnhandledErrorResult
 	defer att.rc.Close()
 
@@ -10,6 +10,7 @@ import (
 	"time"
 
 	"github.com/vela-ssoc/backend-common/httpx"
+	"github.com/vela-ssoc/backend-common/throttle"
 	"github.com/vela-ssoc/backend-common/transmit/opcode"
 )
 
@@ -19,7 +20,15 @@ func NewClient(trip http.RoundTripper) Client {
 }
 
 type Client struct {
-	cli httpx.Client
+	cli    httpx.Client
+	limits throttle.Limiters // 附件下载限速
+}
+
+// WithLimiter 返回附件下载限速的客户端，原客户端不受影响。
+func (c Client) WithLimiter(lims ...*throttle.Limiter) Client {
+	limits := make(throttle.Limiters, 0, len(c.limits)+len(lims))
+	c.limits = append(append(limits, c.limits...), lims...)
+	return c
 }
 
 func (c Client) Fetch(ctx context.Context, op opcode.URLer, rd io.Reader, header http.Header) (*http.Response, error) {
@@ -52,6 +61,9 @@ func (c Client) Attachment(ctx context.Context, op opcode.URLer) (Attachment, er
 		return Attachment{}, err
 	}
 	att := Attachment{code: resp.StatusCode, rc: resp.Body}
+	if len(c.limits) != 0 {
+		att = att.Throttle(ctx, c.limits...)
+	}
 	cd := resp.Header.Get("Content-Disposition")
 	if _, params, _ := mime.ParseMediaType(cd); params != nil {
 		att.Filename = params["filename"]
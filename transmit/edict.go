@@ -0,0 +1,193 @@
+package transmit
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/vela-ssoc/backend-common/problem"
+	"github.com/vela-ssoc/backend-common/transmit/opcode"
+	"github.com/vela-ssoc/backend-common/transmit/opdata"
+)
+
+// ErrEdictNotFound 没有注册对应的 edict 处理器
+var ErrEdictNotFound = errors.New("edict handler not found")
+
+// EdictDispatcher broker 端 edict 事件分发器：按照路径注册各类 edict
+// （如：opcode.EdictSubstanceEvent opcode.EdictCommandEvent
+// opcode.EdictEventRemove）的处理方法，解码 manager 下发的报文后以有限的
+// 并发度逐个通知波及的 agent 节点，并将每个节点的投递结果回复给 manager。
+type EdictDispatcher struct {
+	name     string
+	workers  int
+	timeout  time.Duration
+	mutex    sync.RWMutex
+	handlers map[string]edictDecoder
+}
+
+// edictDecoder 解码 original 报文，返回绑定了解码结果的单节点处理方法。
+type edictDecoder func(json.RawMessage) (func(context.Context, int64) error, error)
+
+// NewEdict 新建 edict 分发器，workers 为同时处理的节点数，timeout 为单个
+// 节点的处理超时时间（小于等于 0 代表不限制）。
+func NewEdict(name string, workers int, timeout time.Duration) *EdictDispatcher {
+	if workers <= 0 {
+		workers = 16
+	}
+	return &EdictDispatcher{
+		name:     name,
+		workers:  workers,
+		timeout:  timeout,
+		handlers: make(map[string]edictDecoder, 8),
+	}
+}
+
+// HandleEdict 注册 edict 处理方法，报文中的 original 会被解码为 T，
+// 解码只会进行一次，然后分发给每个波及的节点。
+func HandleEdict[T any](d *EdictDispatcher, op opcode.URLer, fn func(ctx context.Context, minionID int64, data T) error) {
+	decode := func(raw json.RawMessage) (func(context.Context, int64) error, error) {
+		var data T
+		if len(raw) != 0 && string(raw) != "null" {
+			if err := json.Unmarshal(raw, &data); err != nil {
+				return nil, err
+			}
+		}
+		return func(ctx context.Context, mid int64) error {
+			return fn(ctx, mid, data)
+		}, nil
+	}
+
+	d.mutex.Lock()
+	d.handlers[op.Path()] = decode
+	d.mutex.Unlock()
+}
+
+// Dispatch 分发 edict 事件，body 兼容 opdata.EdictSubstanceEvent 与
+// opdata.BrokerReceive 两种格式。
+func (d *EdictDispatcher) Dispatch(ctx context.Context, path string, body []byte) (*opdata.EdictReport, error) {
+	d.mutex.RLock()
+	decode, ok := d.handlers[path]
+	d.mutex.RUnlock()
+	if !ok {
+		return nil, ErrEdictNotFound
+	}
+
+	var req edictRequest
+	if err := json.Unmarshal(body, &req); err != nil {
+		return nil, err
+	}
+	handle, err := decode(req.Original)
+	if err != nil {
+		return nil, err
+	}
+
+	ids := req.minionIDs()
+	report := &opdata.EdictReport{
+		Path:    path,
+		Total:   len(ids),
+		Results: make([]*opdata.EdictResult, len(ids)),
+	}
+
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, d.workers)
+	for i, mid := range ids {
+		ret := &opdata.EdictResult{MinionID: mid}
+		report.Results[i] = ret
+
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+			ret.Reason = ctx.Err().Error()
+			continue
+		}
+		wg.Add(1)
+		go func(ret *opdata.EdictResult) {
+			defer func() {
+				if v := recover(); v != nil {
+					ret.Succeed, ret.Reason = false, "edict handler panic"
+				}
+				<-sem
+				wg.Done()
+			}()
+			if err := d.call(ctx, handle, ret.MinionID); err != nil {
+				ret.Reason = err.Error()
+			} else {
+				ret.Succeed = true
+			}
+		}(ret)
+	}
+	wg.Wait()
+
+	for _, ret := range report.Results {
+		if ret.Succeed {
+			report.Succeed++
+		} else {
+			report.Failed++
+		}
+	}
+
+	return report, nil
+}
+
+// ServeHTTP 实现 http.Handler，按照请求路径分发 edict 事件并以 JSON 回复投递报告。
+func (d *EdictDispatcher) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var req json.RawMessage
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err == nil {
+		var report *opdata.EdictReport
+		if report, err = d.Dispatch(r.Context(), r.URL.Path, req); err == nil {
+			w.Header().Set("Content-Type", "application/json; charset=utf-8")
+			_ = json.NewEncoder(w).Encode(report)
+			return
+		}
+	}
+
+	code := http.StatusBadRequest
+	if err == ErrEdictNotFound {
+		code = http.StatusNotFound
+	}
+	pd := &problem.Detail{
+		Type:     d.name,
+		Title:    "edict 事件处理错误",
+		Status:   code,
+		Detail:   err.Error(),
+		Instance: r.RequestURI,
+	}
+	_ = pd.JSON(w)
+}
+
+func (d *EdictDispatcher) call(parent context.Context, handle func(context.Context, int64) error, mid int64) error {
+	ctx := parent
+	if d.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(parent, d.timeout)
+		defer cancel()
+	}
+	return handle(ctx, mid)
+}
+
+// edictRequest manager 下发的 edict 报文
+type edictRequest struct {
+	MinionID  []int64         `json:"minion_id"`  // opdata.EdictSubstanceEvent
+	MinionIDs []int64         `json:"minion_ids"` // opdata.BrokerReceive
+	Original  json.RawMessage `json:"original"`
+}
+
+// minionIDs 合并并去重波及的节点
+func (er edictRequest) minionIDs() []int64 {
+	size := len(er.MinionID) + len(er.MinionIDs)
+	ids := make([]int64, 0, size)
+	uniq := make(map[int64]struct{}, size)
+	for _, list := range [][]int64{er.MinionID, er.MinionIDs} {
+		for _, id := range list {
+			if _, ok := uniq[id]; !ok {
+				uniq[id] = struct{}{}
+				ids = append(ids, id)
+			}
+		}
+	}
+	return ids
+}
@@ -1,42 +1,191 @@
 package transmit
 
 import (
+	"context"
+	"errors"
+	"io"
+	"net"
 	"net/http"
 	"net/http/httputil"
+	"strconv"
+	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
 
+	"github.com/vela-ssoc/backend-common/model"
 	"github.com/vela-ssoc/backend-common/problem"
 	"github.com/vela-ssoc/backend-common/transmit/opurl"
 )
 
+// ErrResponseTooLarge 上游响应超过了 WithMaxResponseSize 设置的大小
+var ErrResponseTooLarge = errors.New("proxy upstream response too large")
+
 type Forwarder interface {
 	Forward(opurl.URLer, http.ResponseWriter, *http.Request)
 }
 
-func NewForward(trip http.RoundTripper, name string) Forwarder {
+// ForwardOption 代理转发参数
+type ForwardOption func(*forwardOption)
+
+type forwardOption struct {
+	allow     map[string]struct{}                             // 请求头白名单，为空代表不限制
+	deny      []string                                        // 请求头黑名单
+	rewrites  []func(*httputil.ProxyRequest)                  // 请求改写
+	modifies  []func(*http.Response) error                    // 响应改写
+	maxsize   int64                                           // 最大响应大小
+	timeout   time.Duration                                   // 等待上游响应头的超时时间
+	flush     time.Duration                                   // 响应 flush 间隔
+	auditMax  int64                                           // 审计时最多记录的请求 body 字节数
+	auditFunc func(*ForwardAudit)                             // 审计回调
+	errorFunc func(http.ResponseWriter, *http.Request, error) // 错误处理
+}
+
+// WithAllowHeaders 请求头白名单，只有白名单内的请求头会转发给上游。
+func WithAllowHeaders(keys ...string) ForwardOption {
+	return func(opt *forwardOption) {
+		if opt.allow == nil {
+			opt.allow = make(map[string]struct{}, len(keys))
+		}
+		for _, k := range keys {
+			opt.allow[http.CanonicalHeaderKey(k)] = struct{}{}
+		}
+	}
+}
+
+// WithDenyHeaders 请求头黑名单，如：Cookie Authorization 等不应透传给 agent 的头。
+func WithDenyHeaders(keys ...string) ForwardOption {
+	return func(opt *forwardOption) {
+		opt.deny = append(opt.deny, keys...)
+	}
+}
+
+// WithRewrite 自定义请求改写，在 URL 与 X-Forwarded 设置之后执行，
+// 常用于注入操作人身份等信息。
+func WithRewrite(fn func(*httputil.ProxyRequest)) ForwardOption {
+	return func(opt *forwardOption) {
+		if fn != nil {
+			opt.rewrites = append(opt.rewrites, fn)
+		}
+	}
+}
+
+// WithModifyResponse 自定义响应改写，返回错误会交由错误处理。
+func WithModifyResponse(fn func(*http.Response) error) ForwardOption {
+	return func(opt *forwardOption) {
+		if fn != nil {
+			opt.modifies = append(opt.modifies, fn)
+		}
+	}
+}
+
+// WithMaxResponseSize 限制上游响应 body 的大小。
+func WithMaxResponseSize(n int64) ForwardOption {
+	return func(opt *forwardOption) {
+		opt.maxsize = n
+	}
+}
+
+// WithUpstreamTimeout 等待上游响应头的超时时间，收到响应头后不再计时，
+// 所以不会影响 SSE 等长连接。
+func WithUpstreamTimeout(d time.Duration) ForwardOption {
+	return func(opt *forwardOption) {
+		opt.timeout = d
+	}
+}
+
+// WithFlushInterval 响应 flush 间隔，负数代表每次写入后立即 flush。
+// text/event-stream 与未知长度的流式响应无论如何都会立即 flush。
+func WithFlushInterval(d time.Duration) ForwardOption {
+	return func(opt *forwardOption) {
+		opt.flush = d
+	}
+}
+
+// WithAudit 记录代理的请求，max 为最多记录的请求 body 字节数，
+// fn 在每次转发结束后同步调用。
+func WithAudit(max int64, fn func(*ForwardAudit)) ForwardOption {
+	return func(opt *forwardOption) {
+		opt.auditMax = max
+		opt.auditFunc = fn
+	}
+}
+
+// ForwardAudit 代理转发记录
+type ForwardAudit struct {
+	Request    *http.Request // 原始请求
+	ClientAddr string        // 客户端地址
+	DirectAddr string        // 直连地址
+	Method     string        // 请求方法
+	Path       string        // 请求路径
+	Query      string        // query 参数
+	Length     int64         // 请求 body 实际长度
+	Content    []byte        // 请求 body 内容（最多 max 字节）
+	Status     int           // 响应状态码
+	Failed     bool          // 是否出错
+	Cause      string        // 错误原因
+	RequestAt  time.Time     // 请求时间
+	Elapsed    time.Duration // 耗时
+}
+
+// Oplog 转为操作日志，用户信息与路由名字需要调用方补充。
+func (fa *ForwardAudit) Oplog() *model.Oplog {
+	return &model.Oplog{
+		ClientAddr: fa.ClientAddr,
+		DirectAddr: fa.DirectAddr,
+		Method:     fa.Method,
+		Path:       fa.Path,
+		Query:      fa.Query,
+		Length:     fa.Length,
+		Content:    fa.Content,
+		Failed:     fa.Failed,
+		Cause:      fa.Cause,
+		RequestAt:  fa.RequestAt,
+		Elapsed:    fa.Elapsed,
+	}
+}
+
+func NewForward(trip http.RoundTripper, name string, opts ...ForwardOption) Forwarder {
+	opt := new(forwardOption)
+	for _, fn := range opts {
+		fn(opt)
+	}
+	opt.errorFunc = func(w http.ResponseWriter, r *http.Request, err error) {
+		if st := forwardStateFrom(r.Context()); st != nil {
+			err = st.fail(err)
+		}
+		code := http.StatusBadRequest
+		if err == ErrResponseTooLarge {
+			code = http.StatusBadGateway
+		} else if errors.Is(err, context.DeadlineExceeded) {
+			code = http.StatusGatewayTimeout
+		}
+		pd := &problem.Detail{
+			Type:     name,
+			Title:    "代理转发错误",
+			Status:   code,
+			Detail:   err.Error(),
+			Instance: r.RequestURI,
+		}
+		_ = pd.JSON(w)
+	}
+
 	newFn := func() any {
 		return &httputil.ReverseProxy{
-			Transport: trip,
-			ErrorHandler: func(w http.ResponseWriter, r *http.Request, err error) {
-				code := http.StatusBadRequest
-				pd := &problem.Detail{
-					Type:     name,
-					Title:    "代理转发错误",
-					Status:   code,
-					Detail:   err.Error(),
-					Instance: r.RequestURI,
-				}
-				_ = pd.JSON(w)
-			},
+			Transport:     trip,
+			FlushInterval: opt.flush,
+			ErrorHandler:  opt.errorFunc,
 		}
 	}
 
 	return &proxy{
+		opt:  opt,
 		pool: sync.Pool{New: newFn},
 	}
 }
 
 type proxy struct {
+	opt  *forwardOption
 	pool sync.Pool
 }
 
@@ -44,11 +193,63 @@ func (p *proxy) Forward(op opurl.URLer, w http.ResponseWriter, r *http.Request)
 	px := p.get()
 	defer p.put(px)
 
-	px.Rewrite = func(r *httputil.ProxyRequest) {
-		r.Out.URL = op.URL()
-		r.SetXForwarded()
+	opt := p.opt
+	st := &forwardState{}
+	ctx := context.WithValue(r.Context(), forwardStateKey{}, st)
+	if opt.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithCancel(ctx)
+		defer cancel()
+		st.timer = time.AfterFunc(opt.timeout, func() {
+			st.timeout.Store(true)
+			cancel()
+		})
+		defer st.timer.Stop()
+	}
+	r = r.WithContext(ctx)
+
+	var audit *ForwardAudit
+	if opt.auditFunc != nil {
+		audit = p.newAudit(r)
+		st.body = &auditBody{rc: r.Body, max: opt.auditMax}
+		if r.Body != nil && r.Body != http.NoBody {
+			r.Body = st.body
+		}
+	}
+
+	px.Rewrite = func(pr *httputil.ProxyRequest) {
+		pr.Out.URL = op.URL()
+		pr.SetXForwarded()
+		p.filterHeader(pr.Out.Header)
+		for _, fn := range opt.rewrites {
+			fn(pr)
+		}
+	}
+	px.ModifyResponse = func(res *http.Response) error {
+		if st.timer != nil {
+			st.timer.Stop()
+		}
+		if max := opt.maxsize; max > 0 {
+			if res.ContentLength > max {
+				_ = res.Body.Close()
+				return ErrResponseTooLarge
+			}
+			res.Body = &limitBody{rc: res.Body, remain: max, state: st}
+		}
+		for _, fn := range opt.modifies {
+			if err := fn(res); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	sw := &statusWriter{ResponseWriter: w, code: http.StatusOK}
+	if audit != nil {
+		// 响应中途出错时 ReverseProxy 会 panic(http.ErrAbortHandler)，放在 defer 中确保审计记录不丢失
+		defer func() { p.finishAudit(audit, st, sw.code) }()
 	}
-	px.ServeHTTP(w, r)
+	px.ServeHTTP(sw, r)
 }
 
 func (p *proxy) get() *httputil.ReverseProxy {
@@ -57,5 +258,176 @@ func (p *proxy) get() *httputil.ReverseProxy {
 }
 
 func (p *proxy) put(px *httputil.ReverseProxy) {
+	px.Rewrite = nil
+	px.ModifyResponse = nil
 	p.pool.Put(px)
 }
+
+// filterHeader 按照黑白名单过滤请求头
+func (p *proxy) filterHeader(h http.Header) {
+	if allow := p.opt.allow; len(allow) != 0 {
+		for k := range h {
+			if _, ok := allow[k]; !ok && !strings.HasPrefix(k, "X-Forwarded-") {
+				h.Del(k)
+			}
+		}
+	}
+	for _, k := range p.opt.deny {
+		h.Del(k)
+	}
+}
+
+func (p *proxy) newAudit(r *http.Request) *ForwardAudit {
+	direct, _, _ := net.SplitHostPort(r.RemoteAddr)
+	if direct == "" {
+		direct = r.RemoteAddr
+	}
+	client := direct
+	if xff := r.Header.Get("X-Forwarded-For"); xff != "" {
+		client = strings.TrimSpace(strings.SplitN(xff, ",", 2)[0])
+	} else if xri := r.Header.Get("X-Real-IP"); xri != "" {
+		client = xri
+	}
+
+	return &ForwardAudit{
+		Request:    r,
+		ClientAddr: client,
+		DirectAddr: direct,
+		Method:     r.Method,
+		Path:       r.URL.Path,
+		Query:      r.URL.RawQuery,
+		RequestAt:  time.Now(),
+	}
+}
+
+func (p *proxy) finishAudit(audit *ForwardAudit, st *forwardState, code int) {
+	audit.Elapsed = time.Since(audit.RequestAt)
+	audit.Status = code
+	if body := st.body; body != nil {
+		audit.Length = body.n
+		audit.Content = body.buf
+	}
+	if err := st.err; err != nil {
+		audit.Failed = true
+		audit.Cause = err.Error()
+	} else if code >= http.StatusBadRequest {
+		audit.Failed = true
+		audit.Cause = "upstream response status " + strconv.Itoa(code)
+	}
+
+	p.opt.auditFunc(audit)
+}
+
+type forwardStateKey struct{}
+
+// forwardState 单次转发过程中的状态
+type forwardState struct {
+	timer   *time.Timer
+	timeout atomic.Bool
+	body    *auditBody
+	mu      sync.Mutex
+	err     error
+}
+
+func forwardStateFrom(ctx context.Context) *forwardState {
+	st, _ := ctx.Value(forwardStateKey{}).(*forwardState)
+	return st
+}
+
+// fail 记录转发错误，由超时触发的 context.Canceled 会转为 context.DeadlineExceeded。
+func (st *forwardState) fail(err error) error {
+	if st.timeout.Load() && errors.Is(err, context.Canceled) {
+		err = context.DeadlineExceeded
+	}
+	st.mu.Lock()
+	if st.err == nil {
+		st.err = err
+	}
+	st.mu.Unlock()
+
+	return err
+}
+
+// auditBody 转发请求 body 的同时记录前 max 个字节
+type auditBody struct {
+	rc  io.ReadCloser
+	max int64
+	n   int64
+	buf []byte
+}
+
+func (ab *auditBody) Read(p []byte) (int, error) {
+	n, err := ab.rc.Read(p)
+	if n > 0 {
+		ab.n += int64(n)
+		if remain := ab.max - int64(len(ab.buf)); remain > 0 {
+			sz := int64(n)
+			if sz > remain {
+				sz = remain
+			}
+			ab.buf = append(ab.buf, p[:sz]...)
+		}
+	}
+	return n, err
+}
+
+func (ab *auditBody) Close() error { return ab.rc.Close() }
+
+// limitBody 限制响应 body 大小，超过限制时返回 ErrResponseTooLarge
+type limitBody struct {
+	rc     io.ReadCloser
+	remain int64
+	state  *forwardState
+}
+
+func (lb *limitBody) Read(p []byte) (int, error) {
+	if lb.remain < 0 {
+		return 0, ErrResponseTooLarge
+	}
+	// 多读一个字节用于判断是否超出了限制
+	if int64(len(p)) > lb.remain+1 {
+		p = p[:lb.remain+1]
+	}
+	n, err := lb.rc.Read(p)
+	if int64(n) > lb.remain {
+		n = int(lb.remain)
+		lb.remain = -1
+		lb.state.fail(ErrResponseTooLarge)
+		return n, ErrResponseTooLarge
+	}
+	lb.remain -= int64(n)
+
+	return n, err
+}
+
+func (lb *limitBody) Close() error { return lb.rc.Close() }
+
+// statusWriter 记录响应状态码，同时通过 Unwrap 保留 http.Flusher 等能力。
+type statusWriter struct {
+	http.ResponseWriter
+	code  int
+	wrote bool
+}
+
+func (sw *statusWriter) WriteHeader(code int) {
+	if !sw.wrote && code >= http.StatusOK {
+		sw.wrote = true
+		sw.code = code
+	}
+	sw.ResponseWriter.WriteHeader(code)
+}
+
+func (sw *statusWriter) Write(p []byte) (int, error) {
+	sw.wrote = true
+	return sw.ResponseWriter.Write(p)
+}
+
+func (sw *statusWriter) Flush() {
+	if f, ok := sw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (sw *statusWriter) Unwrap() http.ResponseWriter {
+	return sw.ResponseWriter
+}
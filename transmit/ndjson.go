@@ -0,0 +1,202 @@
+package transmit
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"mime"
+	"net/http"
+	"sync"
+
+	"github.com/vela-ssoc/backend-common/transmit/opcode"
+)
+
+const (
+	// MIMENDJSON 换行分隔的 JSON 流 https://github.com/ndjson/ndjson-spec
+	MIMENDJSON = "application/x-ndjson"
+
+	// MIMEJSONSeq JSON 文本序列 RFC7464 https://www.rfc-editor.org/rfc/rfc7464
+	MIMEJSONSeq = "application/json-seq"
+
+	// recordSeparator RFC7464 中每条记录的起始分隔符
+	recordSeparator = 0x1e
+)
+
+// JSONStream 流式 JSON 解码器，用于逐条消费 agent 长连接响应（如：日志 tail、
+// 文件扫描结果、进程事件等），兼容 NDJSON 与 JSON-seq 两种格式。
+type JSONStream struct {
+	rc     io.ReadCloser
+	dec    *json.Decoder
+	ctx    context.Context
+	cancel context.CancelFunc
+	once   sync.Once
+}
+
+// JSONStream 发起请求并返回流式解码器，使用完毕后必须调用 Close。
+// ctx 取消后底层 body 会被关闭，阻塞中的 Decode 会立即返回错误。
+func (c Client) JSONStream(ctx context.Context, op opcode.URLer, body any) (*JSONStream, error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	rd, err := c.toJSON(body)
+	if err != nil {
+		return nil, err
+	}
+	header := make(http.Header, 2)
+	header.Set("Accept", MIMENDJSON+", "+MIMEJSONSeq)
+	if rd != nil {
+		header.Set("Content-Type", "application/json; charset=UTF-8")
+	}
+
+	res, err := c.cli.Fetch(ctx, op.Method(), op.URL(), rd, header)
+	if err != nil {
+		return nil, err
+	}
+
+	return newJSONStream(ctx, res), nil
+}
+
+// Decode 解码下一条记录，流结束时返回 io.EOF。
+func (js *JSONStream) Decode(v any) error {
+	if err := js.dec.Decode(v); err != nil {
+		if ce := js.ctx.Err(); ce != nil {
+			return ce
+		}
+		return err
+	}
+	return nil
+}
+
+// Close 关闭流
+func (js *JSONStream) Close() error {
+	var err error
+	js.once.Do(func() {
+		js.cancel()
+		err = js.rc.Close()
+	})
+	return err
+}
+
+// StreamJSON 逐条解码 op 的响应并回调 fn，fn 返回错误或 ctx 取消时终止。
+// 流正常结束时返回 nil。
+func StreamJSON[T any](ctx context.Context, c Client, op opcode.URLer, body any, fn func(T) error) error {
+	js, err := c.JSONStream(ctx, op, body)
+	if err != nil {
+		return err
+	}
+	//goland:noinspection GoUnhandledErrorResult
+	defer js.Close()
+
+	for {
+		var v T
+		if err = js.Decode(&v); err != nil {
+			if err == io.EOF {
+				err = nil
+			}
+			return err
+		}
+		if err = fn(v); err != nil {
+			return err
+		}
+	}
+}
+
+func newJSONStream(parent context.Context, res *http.Response) *JSONStream {
+	ctx, cancel := context.WithCancel(parent)
+	js := &JSONStream{rc: res.Body, ctx: ctx, cancel: cancel}
+
+	var rd io.Reader = res.Body
+	if mt, _, _ := mime.ParseMediaType(res.Header.Get("Content-Type")); mt == MIMEJSONSeq {
+		rd = &seqReader{rd: bufio.NewReader(res.Body)}
+	}
+	js.dec = json.NewDecoder(rd)
+
+	go func() {
+		<-ctx.Done()
+		_ = js.Close()
+	}()
+
+	return js
+}
+
+// seqReader 剔除 JSON-seq 记录前的 RS 分隔符，记录之间以 LF 分隔，
+// 剩余内容可直接交给 json.Decoder 处理。
+type seqReader struct {
+	rd *bufio.Reader
+}
+
+func (sr *seqReader) Read(p []byte) (int, error) {
+	var n int
+	for n < len(p) {
+		b, err := sr.rd.ReadByte()
+		if err != nil {
+			if n > 0 {
+				return n, nil
+			}
+			return 0, err
+		}
+		if b == recordSeparator {
+			continue
+		}
+		p[n] = b
+		n++
+		if sr.rd.Buffered() == 0 {
+			break
+		}
+	}
+	return n, nil
+}
+
+// JSONWriter 服务端流式 JSON 写入器，每写入一条记录就 flush 一次，
+// 确保客户端可以实时收到数据。
+type JSONWriter struct {
+	w       http.ResponseWriter
+	flusher http.Flusher
+	seq     bool
+	wrote   bool
+}
+
+// NewJSONWriter 新建流式写入器，seq 为 true 时使用 JSON-seq 格式，否则为 NDJSON。
+func NewJSONWriter(w http.ResponseWriter, seq bool) *JSONWriter {
+	flusher, _ := w.(http.Flusher)
+	return &JSONWriter{w: w, flusher: flusher, seq: seq}
+}
+
+// Write 写入一条记录并 flush
+func (jw *JSONWriter) Write(v any) error {
+	if !jw.wrote {
+		jw.wrote = true
+		ct := MIMENDJSON
+		if jw.seq {
+			ct = MIMEJSONSeq
+		}
+		jw.w.Header().Set("Content-Type", ct)
+		jw.w.Header().Set("Cache-Control", "no-cache")
+		jw.w.WriteHeader(http.StatusOK)
+	}
+
+	dat, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	buf := make([]byte, 0, len(dat)+2)
+	if jw.seq {
+		buf = append(buf, recordSeparator)
+	}
+	buf = append(buf, dat...)
+	buf = append(buf, '\n')
+	if _, err = jw.w.Write(buf); err != nil {
+		return err
+	}
+	jw.Flush()
+
+	return nil
+}
+
+// Flush 立即将缓冲数据发送给客户端
+func (jw *JSONWriter) Flush() {
+	if jw.flusher != nil {
+		jw.flusher.Flush()
+	}
+}
@@ -0,0 +1,17 @@
+package opdata
+
+// EdictResult 单个 agent 的 edict 事件投递结果
+type EdictResult struct {
+	MinionID int64  `json:"minion_id"`        // agent 节点 ID
+	Succeed  bool   `json:"succeed"`          // 是否投递成功
+	Reason   string `json:"reason,omitempty"` // 失败原因
+}
+
+// EdictReport broker 回复给 manager 的 edict 事件投递报告
+type EdictReport struct {
+	Path    string         `json:"path"`    // edict 路径
+	Total   int            `json:"total"`   // 波及的节点数
+	Succeed int            `json:"succeed"` // 投递成功数
+	Failed  int            `json:"failed"`  // 投递失败数
+	Results []*EdictResult `json:"results"` // 每个节点的投递结果
+}
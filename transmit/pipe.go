@@ -1,31 +1,314 @@
 package transmit
 
-import "github.com/gorilla/websocket"
+import (
+	"errors"
+	"io"
+	"sync"
+	"sync/atomic"
+	"time"
 
+	"github.com/gorilla/websocket"
+)
+
+// ErrPipeIdle 在 PipeOptions.IdleTimeout 时间内双方都没有数据交互
+var ErrPipeIdle = errors.New("websocket pipe idle timeout")
+
+// PipeDirection 数据流向
+type PipeDirection uint8
+
+const (
+	PipeForward  PipeDirection = iota // fore -> back
+	PipeBackward                      // back -> fore
+)
+
+func (d PipeDirection) String() string {
+	if d == PipeForward {
+		return "forward"
+	}
+	return "backward"
+}
+
+// PipeInspector 流量观察钩子，常用于审计代理到 agent 的 shell/console 会话。
+// 大消息会以流的方式转发，所以同一条消息可能分多次回调，final 为 true
+// 代表该条消息结束，此时 err 不为 nil 说明消息在中途中断，没有完整转发。
+// p 只在回调期间有效，如需保存请自行拷贝。
+type PipeInspector func(dir PipeDirection, messageType int, p []byte, final bool, err error)
+
+// PipeOptions websocket 双向转发参数，零值代表不启用对应功能。
+type PipeOptions struct {
+	PingInterval   time.Duration // 向两端发送 ping 的间隔，同时 2 倍该时间收不到任何帧视为断开
+	WriteTimeout   time.Duration // 单条消息/控制帧写超时
+	IdleTimeout    time.Duration // 双方都没有数据消息的最长时间（ping/pong 不算）
+	MaxMessageSize int64         // 单条消息最大字节数
+	Inspector      PipeInspector // 流量观察钩子
+}
+
+// Pipe 将两个 websocket 连接的消息双向转发，任意一端断开后两端都会关闭。
 func Pipe(fore, back *websocket.Conn) {
+	_ = PipeWith(fore, back, PipeOptions{})
+}
+
+// PipeWith 按照 opt 双向转发两个 websocket 连接，直到任意一端断开或触发限制，
+// 返回导致转发结束的原因，正常关闭时返回 nil。
+func PipeWith(fore, back *websocket.Conn, opt PipeOptions) error {
+	pp := &piper{
+		fore: fore,
+		back: back,
+		opt:  opt,
+		done: make(chan struct{}),
+	}
+	pp.touch()
+
+	return pp.serve()
+}
+
+type piper struct {
+	fore   *websocket.Conn
+	back   *websocket.Conn
+	opt    PipeOptions
+	active atomic.Int64 // 最后一次有数据消息的时间（UnixNano）
+	done   chan struct{}
+	once   sync.Once
+	err    error
+}
+
+func (pp *piper) serve() error {
+	pp.prepare(pp.fore)
+	pp.prepare(pp.back)
+
+	var wg sync.WaitGroup
+	wg.Add(2)
 	go func() {
-		for {
-			mt, p, err := fore.ReadMessage()
-			if err == nil {
-				err = back.WriteMessage(mt, p)
+		defer wg.Done()
+		pp.copy(PipeForward, pp.back, pp.fore)
+	}()
+	go func() {
+		defer wg.Done()
+		pp.copy(PipeBackward, pp.fore, pp.back)
+	}()
+	if pp.opt.PingInterval > 0 || pp.opt.IdleTimeout > 0 {
+		go pp.heartbeat()
+	}
+	wg.Wait()
+
+	return pp.err
+}
+
+// prepare 设置读限制、读超时和控制帧处理
+func (pp *piper) prepare(conn *websocket.Conn) {
+	if n := pp.opt.MaxMessageSize; n > 0 {
+		conn.SetReadLimit(n)
+	}
+	if pp.opt.PingInterval <= 0 {
+		return
+	}
+
+	pp.extendRead(conn)
+	conn.SetPongHandler(func(string) error {
+		pp.extendRead(conn)
+		return nil
+	})
+	conn.SetPingHandler(func(data string) error {
+		pp.extendRead(conn)
+		err := conn.WriteControl(websocket.PongMessage, []byte(data), pp.deadline())
+		if err == websocket.ErrCloseSent {
+			return nil
+		}
+		return err
+	})
+}
+
+// copy 将 src 的消息以流的方式写入 dst
+func (pp *piper) copy(dir PipeDirection, dst, src *websocket.Conn) {
+	buf := make([]byte, 32*1024)
+	for {
+		mt, rd, err := src.NextReader()
+		if err != nil {
+			pp.closeFrom(src, dst, err)
+			return
+		}
+		pp.touch()
+		if pp.opt.PingInterval > 0 {
+			pp.extendRead(src)
+		}
+		if err = pp.forward(dir, mt, dst, rd, buf); err != nil {
+			pp.closeFrom(src, dst, err)
+			return
+		}
+	}
+}
+
+func (pp *piper) forward(dir PipeDirection, mt int, dst *websocket.Conn, rd io.Reader, buf []byte) error {
+	if pp.opt.WriteTimeout > 0 {
+		_ = dst.SetWriteDeadline(pp.deadline())
+	}
+	w, err := dst.NextWriter(mt)
+	if err != nil {
+		return &pipeWriteError{err: err}
+	}
+
+	// 中途出错时不能调用 w.Close，否则残缺的消息会被当作完整的消息发给 dst，
+	// 由 closeFrom 发送关闭帧中断 dst 上这条未完成的消息。
+	inspect := pp.opt.Inspector
+	for {
+		n, re := rd.Read(buf)
+		if n > 0 {
+			if inspect != nil {
+				inspect(dir, mt, buf[:n], false, nil)
 			}
-			if err != nil {
-				_ = back.Close()
-				_ = fore.Close()
-				break
+			if _, we := w.Write(buf[:n]); we != nil {
+				if inspect != nil {
+					inspect(dir, mt, nil, true, we)
+				}
+				return &pipeWriteError{err: we}
 			}
 		}
-	}()
+		if re == io.EOF {
+			break
+		}
+		if re != nil {
+			if inspect != nil {
+				inspect(dir, mt, nil, true, re)
+			}
+			return &pipeAbortError{err: re}
+		}
+	}
+	if inspect != nil {
+		inspect(dir, mt, nil, true, nil)
+	}
+	if err = w.Close(); err != nil {
+		return &pipeWriteError{err: err}
+	}
+
+	return nil
+}
+
+// heartbeat 定时发送 ping 并检查空闲超时
+func (pp *piper) heartbeat() {
+	var pingC, idleC <-chan time.Time
+	if interval := pp.opt.PingInterval; interval > 0 {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		pingC = ticker.C
+	}
+	idle := pp.opt.IdleTimeout
+	if idle > 0 {
+		// IdleTimeout 过小时 idle/4 可能为 0，NewTicker 会 panic
+		interval := idle / 4
+		if interval < time.Millisecond {
+			interval = time.Millisecond
+		}
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		idleC = ticker.C
+	}
 
 	for {
-		mt, p, err := back.ReadMessage()
-		if err == nil {
-			err = fore.WriteMessage(mt, p)
+		select {
+		case <-pp.done:
+			return
+		case <-pingC:
+			deadline := pp.deadline()
+			_ = pp.fore.WriteControl(websocket.PingMessage, nil, deadline)
+			_ = pp.back.WriteControl(websocket.PingMessage, nil, deadline)
+		case now := <-idleC:
+			last := time.Unix(0, pp.active.Load())
+			if now.Sub(last) >= idle {
+				pp.shutdown(ErrPipeIdle, websocket.CloseGoingAway, "idle timeout", websocket.CloseGoingAway, "idle timeout")
+				return
+			}
 		}
-		if err != nil {
-			_ = fore.Close()
-			_ = back.Close()
-			break
+	}
+}
+
+// closeFrom 根据 src 读取/转发时遇到的错误，以合适的关闭码关闭两端
+func (pp *piper) closeFrom(src, dst *websocket.Conn, err error) {
+	var srcCode, dstCode int
+	var srcText, dstText string
+
+	var ce *websocket.CloseError
+	var we *pipeWriteError
+	var ae *pipeAbortError
+	switch {
+	case errors.As(err, &ae):
+		// 消息读到一半中断，dst 已经收到了部分分片，必须以异常关闭码通知 dst 丢弃
+		if errors.Is(err, websocket.ErrReadLimit) {
+			srcCode, srcText = websocket.CloseMessageTooBig, "message too big"
+		}
+		dstCode, dstText = websocket.CloseGoingAway, "message aborted"
+		err = ae.err
+	case errors.As(err, &ce):
+		// 对端主动关闭（底层已经回复过 close 帧），将关闭码透传给另一端
+		dstCode, dstText = ce.Code, ce.Text
+		if dstCode == websocket.CloseNoStatusReceived || dstCode == websocket.CloseAbnormalClosure {
+			dstCode, dstText = websocket.CloseNormalClosure, ""
 		}
+		err = nil
+	case errors.Is(err, websocket.ErrReadLimit):
+		srcCode, srcText = websocket.CloseMessageTooBig, "message too big"
+		dstCode, dstText = websocket.CloseGoingAway, "peer message too big"
+	case errors.As(err, &we):
+		// 写入 dst 失败，说明 dst 已经不可用
+		srcCode, srcText = websocket.CloseGoingAway, "peer gone"
+		err = we.err
+	default:
+		dstCode, dstText = websocket.CloseGoingAway, "peer gone"
+	}
+
+	if src == pp.fore {
+		pp.shutdown(err, srcCode, srcText, dstCode, dstText)
+	} else {
+		pp.shutdown(err, dstCode, dstText, srcCode, srcText)
 	}
 }
+
+// shutdown 向两端发送关闭帧（code 为 0 时不发送）并关闭连接，只会执行一次。
+func (pp *piper) shutdown(err error, foreCode int, foreText string, backCode int, backText string) {
+	pp.once.Do(func() {
+		pp.err = err
+		close(pp.done)
+		deadline := time.Now().Add(time.Second)
+		if foreCode != 0 {
+			msg := websocket.FormatCloseMessage(foreCode, foreText)
+			_ = pp.fore.WriteControl(websocket.CloseMessage, msg, deadline)
+		}
+		if backCode != 0 {
+			msg := websocket.FormatCloseMessage(backCode, backText)
+			_ = pp.back.WriteControl(websocket.CloseMessage, msg, deadline)
+		}
+		_ = pp.fore.Close()
+		_ = pp.back.Close()
+	})
+}
+
+func (pp *piper) touch() {
+	pp.active.Store(time.Now().UnixNano())
+}
+
+func (pp *piper) extendRead(conn *websocket.Conn) {
+	_ = conn.SetReadDeadline(time.Now().Add(2 * pp.opt.PingInterval))
+}
+
+func (pp *piper) deadline() time.Time {
+	timeout := pp.opt.WriteTimeout
+	if timeout <= 0 {
+		timeout = 10 * time.Second
+	}
+	return time.Now().Add(timeout)
+}
+
+// pipeWriteError 标记写入目标端时发生的错误
+type pipeWriteError struct {
+	err error
+}
+
+func (e *pipeWriteError) Error() string { return e.err.Error() }
+func (e *pipeWriteError) Unwrap() error { return e.err }
+
+// pipeAbortError 标记读取消息中途发生的错误，此时消息只转发了一部分
+type pipeAbortError struct {
+	err error
+}
+
+func (e *pipeAbortError) Error() string { return e.err.Error() }
+func (e *pipeAbortError) Unwrap() error { return e.err }
@@ -0,0 +1,154 @@
+package transmit
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// wsPair 建立一对互通的 websocket 连接
+func wsPair(t *testing.T) (client, server *websocket.Conn) {
+	t.Helper()
+	connC := make(chan *websocket.Conn, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := new(websocket.Upgrader).Upgrade(w, r, nil)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		connC <- conn
+	}))
+	t.Cleanup(srv.Close)
+
+	client, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server = <-connC
+	t.Cleanup(func() {
+		_ = client.Close()
+		_ = server.Close()
+	})
+
+	return client, server
+}
+
+// startPipe 启动转发：fore 端由 fore 连接写入，back 端由 back 连接读取
+func startPipe(t *testing.T, opt PipeOptions) (fore, back *websocket.Conn, errC <-chan error) {
+	t.Helper()
+	fore, foreServer := wsPair(t)
+	backClient, back := wsPair(t)
+	ch := make(chan error, 1)
+	go func() { ch <- PipeWith(foreServer, backClient, opt) }()
+
+	return fore, back, ch
+}
+
+func waitPipe(t *testing.T, errC <-chan error) error {
+	t.Helper()
+	select {
+	case err := <-errC:
+		return err
+	case <-time.After(5 * time.Second):
+		t.Fatal("pipe not finished")
+		return nil
+	}
+}
+
+func TestPipeAbort(t *testing.T) {
+	var mu sync.Mutex
+	var aborted error
+	fore, back, errC := startPipe(t, PipeOptions{
+		Inspector: func(dir PipeDirection, _ int, _ []byte, final bool, err error) {
+			if final && dir == PipeForward {
+				mu.Lock()
+				aborted = err
+				mu.Unlock()
+			}
+		},
+	})
+
+	// 只发送消息的前半部分，然后断开底层连接
+	w, err := fore.NextWriter(websocket.BinaryMessage)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = w.Write(bytes.Repeat([]byte("x"), 64*1024)); err != nil {
+		t.Fatal(err)
+	}
+	_, rd, err := back.NextReader()
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = fore.UnderlyingConn().Close()
+
+	// 残缺的消息不能被当作完整的消息收到
+	_, err = io.ReadAll(rd)
+	if !websocket.IsCloseError(err, websocket.CloseGoingAway) {
+		t.Fatalf("unexpected read error: %v", err)
+	}
+	if err = waitPipe(t, errC); err == nil {
+		t.Fatal("expected pipe error")
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if aborted == nil {
+		t.Fatal("inspector not told about the aborted message")
+	}
+}
+
+func TestPipeIdle(t *testing.T) {
+	fore, back, errC := startPipe(t, PipeOptions{IdleTimeout: 100 * time.Millisecond})
+
+	// 有数据交互时不会触发空闲超时
+	for i := 0; i < 4; i++ {
+		if err := fore.WriteMessage(websocket.TextMessage, []byte("ping")); err != nil {
+			t.Fatal(err)
+		}
+		if _, msg, err := back.ReadMessage(); err != nil || string(msg) != "ping" {
+			t.Fatalf("read %q: %v", msg, err)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+
+	if err := waitPipe(t, errC); err != ErrPipeIdle {
+		t.Fatalf("unexpected pipe error: %v", err)
+	}
+	for _, conn := range []*websocket.Conn{fore, back} {
+		if _, _, err := conn.ReadMessage(); !websocket.IsCloseError(err, websocket.CloseGoingAway) {
+			t.Fatalf("unexpected close: %v", err)
+		}
+	}
+}
+
+func TestPipeMaxMessageSize(t *testing.T) {
+	fore, back, errC := startPipe(t, PipeOptions{MaxMessageSize: 1024})
+
+	small := bytes.Repeat([]byte("s"), 1024)
+	if err := fore.WriteMessage(websocket.BinaryMessage, small); err != nil {
+		t.Fatal(err)
+	}
+	if _, msg, err := back.ReadMessage(); err != nil || !bytes.Equal(msg, small) {
+		t.Fatalf("read %d bytes: %v", len(msg), err)
+	}
+
+	if err := fore.WriteMessage(websocket.BinaryMessage, bytes.Repeat([]byte("b"), 1025)); err != nil {
+		t.Fatal(err)
+	}
+	if err := waitPipe(t, errC); err != websocket.ErrReadLimit {
+		t.Fatalf("unexpected pipe error: %v", err)
+	}
+	if _, _, err := fore.ReadMessage(); !websocket.IsCloseError(err, websocket.CloseMessageTooBig) {
+		t.Fatalf("unexpected fore close: %v", err)
+	}
+	if _, _, err := back.ReadMessage(); !websocket.IsCloseError(err, websocket.CloseGoingAway) {
+		t.Fatalf("unexpected back close: %v", err)
+	}
+}
@@ -0,0 +1,184 @@
+package transmit
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/vela-ssoc/backend-common/transmit/opcode"
+)
+
+// ErrStreamDisconnected 自动重连的 websocket 当前处于断开状态
+var ErrStreamDisconnected = errors.New("websocket stream disconnected")
+
+// ReconnectOptions 自动重连参数
+type ReconnectOptions struct {
+	Header     http.Header    // 握手请求头
+	Stream     *StreamOptions // 握手参数
+	MinBackoff time.Duration  // 最小重连间隔，默认 1s
+	MaxBackoff time.Duration  // 最大重连间隔，默认 1min
+	ResetAfter time.Duration  // 连接保持该时长以上，断开后重连间隔才会恢复为 MinBackoff，默认 30s
+
+	// OnConnect 每次连接（包括重连）成功后回调，一般用于重新发送订阅消息，
+	// 返回错误会断开本次连接并继续重连。
+	OnConnect func(ctx context.Context, conn *websocket.Conn) error
+
+	// OnDisconnect 连接断开或拨号失败时回调，retry 为下次重连的等待时间。
+	OnDisconnect func(err error, retry time.Duration)
+}
+
+// Reconnector 自动重连的 websocket，适用于 manager 与 broker 之间长期存在的
+// 事件通道。断线后通过同一个 Streamer（即同一个 dialFn）按照指数退避重连。
+type Reconnector struct {
+	stm  Streamer
+	op   opcode.URLer
+	opt  ReconnectOptions
+	mu   sync.Mutex // 保护 conn 与写操作
+	conn *websocket.Conn
+}
+
+// NewReconnect 新建自动重连的 websocket
+func NewReconnect(stm Streamer, op opcode.URLer, opt ReconnectOptions) *Reconnector {
+	if opt.MinBackoff <= 0 {
+		opt.MinBackoff = time.Second
+	}
+	if opt.MaxBackoff < opt.MinBackoff {
+		opt.MaxBackoff = time.Minute
+		if opt.MaxBackoff < opt.MinBackoff {
+			opt.MaxBackoff = opt.MinBackoff
+		}
+	}
+	if opt.ResetAfter <= 0 {
+		opt.ResetAfter = 30 * time.Second
+	}
+
+	return &Reconnector{stm: stm, op: op, opt: opt}
+}
+
+// Serve 保持连接并将收到的消息交给 handle 处理，直到 ctx 取消或 handle
+// 返回错误。ctx 取消时返回 ctx.Err()。
+func (rc *Reconnector) Serve(ctx context.Context, handle func(messageType int, p []byte) error) error {
+	backoff := rc.opt.MinBackoff
+	for {
+		conn, err := rc.connect(ctx)
+		if err == nil {
+			connectedAt := time.Now()
+			var stop bool
+			stop, err = rc.receive(ctx, conn, handle)
+			if stop {
+				return err
+			}
+			// 连上后立即断开的链路继续退避，避免以 MinBackoff 频繁重连
+			if time.Since(connectedAt) >= rc.opt.ResetAfter {
+				backoff = rc.opt.MinBackoff
+			}
+		}
+		if ce := ctx.Err(); ce != nil {
+			return ce
+		}
+
+		retry := rc.jitter(backoff)
+		if fn := rc.opt.OnDisconnect; fn != nil {
+			fn(err, retry)
+		}
+		timer := time.NewTimer(retry)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+
+		if backoff *= 2; backoff > rc.opt.MaxBackoff {
+			backoff = rc.opt.MaxBackoff
+		}
+	}
+}
+
+// WriteMessage 向当前连接写入消息，断开期间返回 ErrStreamDisconnected。
+func (rc *Reconnector) WriteMessage(messageType int, p []byte) error {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+
+	if rc.conn == nil {
+		return ErrStreamDisconnected
+	}
+	return rc.conn.WriteMessage(messageType, p)
+}
+
+// WriteJSON 向当前连接写入 JSON 消息，断开期间返回 ErrStreamDisconnected。
+func (rc *Reconnector) WriteJSON(v any) error {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+
+	if rc.conn == nil {
+		return ErrStreamDisconnected
+	}
+	return rc.conn.WriteJSON(v)
+}
+
+func (rc *Reconnector) connect(ctx context.Context) (*websocket.Conn, error) {
+	conn, res, err := StreamContext(ctx, rc.stm, rc.op, rc.opt.Header, rc.opt.Stream)
+	if err != nil {
+		return nil, err
+	}
+	if res != nil && res.Body != nil {
+		_ = res.Body.Close()
+	}
+
+	if fn := rc.opt.OnConnect; fn != nil {
+		if err = fn(ctx, conn); err != nil {
+			_ = conn.Close()
+			return nil, err
+		}
+	}
+
+	rc.mu.Lock()
+	rc.conn = conn
+	rc.mu.Unlock()
+
+	return conn, nil
+}
+
+// receive 循环读取消息，返回值 stop 代表是否终止重连。
+func (rc *Reconnector) receive(ctx context.Context, conn *websocket.Conn, handle func(int, []byte) error) (bool, error) {
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			_ = conn.Close()
+		case <-done:
+		}
+	}()
+
+	defer func() {
+		rc.mu.Lock()
+		rc.conn = nil
+		rc.mu.Unlock()
+		_ = conn.Close()
+	}()
+
+	for {
+		mt, p, err := conn.ReadMessage()
+		if err != nil {
+			return false, err
+		}
+		if err = handle(mt, p); err != nil {
+			return true, err
+		}
+	}
+}
+
+// jitter 在退避时间上增加 ±20% 的随机抖动，避免大量连接同时重连。
+func (rc *Reconnector) jitter(d time.Duration) time.Duration {
+	delta := int64(d) / 5
+	if delta <= 0 {
+		return d
+	}
+	return d - time.Duration(delta) + time.Duration(rand.Int63n(2*delta))
+}
@@ -14,6 +14,33 @@ type Streamer interface {
 	Stream(opcode.URLer, http.Header) (*websocket.Conn, *http.Response, error)
 }
 
+// ContextStreamer 支持 ctx 与握手参数的 Streamer，NewStream 返回的 Streamer
+// 实现了该接口。
+type ContextStreamer interface {
+	Streamer
+
+	// StreamContext 使用调用方的 ctx 建立 websocket 连接，opts 可以为 nil。
+	StreamContext(context.Context, opcode.URLer, http.Header, *StreamOptions) (*websocket.Conn, *http.Response, error)
+}
+
+// StreamContext stm 实现了 ContextStreamer 时使用 ctx 与 opts 建立连接，
+// 否则退化为 stm.Stream，忽略 ctx 与 opts。
+func StreamContext(ctx context.Context, stm Streamer, op opcode.URLer, header http.Header, opts *StreamOptions) (*websocket.Conn, *http.Response, error) {
+	if cs, ok := stm.(ContextStreamer); ok {
+		return cs.StreamContext(ctx, op, header, opts)
+	}
+	return stm.Stream(op, header)
+}
+
+// StreamOptions websocket 握手参数，零值代表使用默认配置。
+type StreamOptions struct {
+	Subprotocols       []string      // 子协议
+	DisableCompression bool          // 关闭压缩协商
+	HandshakeTimeout   time.Duration // 握手超时，默认 5s
+	ReadBufferSize     int           // 读缓冲大小，默认 4K
+	WriteBufferSize    int           // 写缓冲大小，默认 4K
+}
+
 func NewStream(dialFn func(context.Context, string, string) (net.Conn, error)) Streamer {
 	dial := &websocket.Dialer{
 		NetDialContext:    dialFn,
@@ -31,10 +58,41 @@ type socketStream struct {
 }
 
 func (ss *socketStream) Stream(op opcode.URLer, header http.Header) (*websocket.Conn, *http.Response, error) {
-	addr := op.URL().String()
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	conn, res, err := ss.dial.DialContext(ctx, addr, header)
+	conn, res, err := ss.StreamContext(ctx, op, header, nil)
 	cancel()
 
 	return conn, res, err
 }
+
+func (ss *socketStream) StreamContext(ctx context.Context, op opcode.URLer, header http.Header, opts *StreamOptions) (*websocket.Conn, *http.Response, error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	addr := op.URL().String()
+	dial := ss.dialer(opts)
+
+	return dial.DialContext(ctx, addr, header)
+}
+
+// dialer 根据 opts 派生 websocket.Dialer，底层仍使用同一个 dialFn。
+func (ss *socketStream) dialer(opts *StreamOptions) *websocket.Dialer {
+	if opts == nil {
+		return ss.dial
+	}
+
+	dial := *ss.dial
+	dial.Subprotocols = opts.Subprotocols
+	dial.EnableCompression = !opts.DisableCompression
+	if opts.HandshakeTimeout > 0 {
+		dial.HandshakeTimeout = opts.HandshakeTimeout
+	}
+	if opts.ReadBufferSize > 0 {
+		dial.ReadBufferSize = opts.ReadBufferSize
+	}
+	if opts.WriteBufferSize > 0 {
+		dial.WriteBufferSize = opts.WriteBufferSize
+	}
+
+	return &dial
+}